@@ -3,64 +3,159 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/twmb/murmur3"
+	"io"
 	"math"
 	"net"
 	"os"
 	"path"
 	"satae66.dev/netzeps2022/network"
 	"satae66.dev/netzeps2022/network/packets"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// socketBufferSize is the requested size of the kernel receive buffer; protocols without flow control rely on it
+const socketBufferSize = 8 * 1024 * 1024
+
+// progressInterval is the number of received bytes after which the progress of a transmission is saved
+const progressInterval = 1024 * 1024
+
+// outputLinger is the time the receiver keeps acknowledging retransmitted finalize-packets after
+// the transmission written to its output finished; the sender fails if the last ack is lost
+const outputLinger = 1 * time.Second
+
+// statsInterval is the minimal time between two reports of the violations of the limits
+const statsInterval = 10 * time.Second
+
+// errNotReady drops a packet without an answer until the background work it depends on is done; the sender
+// retransmits the packet like a lost one
+var errNotReady = errors.New("transmission is not ready")
+
 type Settings struct {
-	networkTimeout time.Duration // timeout as time.Duration after which the connection is closed and the transmission is aborted
+	networkTimeout    time.Duration              // timeout as time.Duration after which the connection is closed and the transmission is aborted
+	protocol          network.Protocol           // transfer protocol used for all transmissions
+	keyring           *network.Keyring           // derives the keys that seal all packets; nil to send them in plain text
+	authorizedSenders *network.AuthorizedSenders // senders that are accepted after authenticating; nil to accept anonymous senders (receiver only)
+	limits            network.Limits             // bounds of the resources spent on the senders (receiver only)
+	overwritePolicy   network.OverwritePolicy    // handling of files that already exist (receiver only)
+	keepPartial       bool                       // keep the partial file of a failed transmission (receiver only)
+	subdirs           bool                       // accept file names with directories (receiver only)
 }
 
 type Receiver struct {
 	settings Settings
 	outPath  string // path of directory in which to store transmissions
 
-	keepRunning bool
+	keepRunning int32 // 1 while running; accessed atomically as Stop may be called from another goroutine
 
 	conn          *net.UDPConn
-	transmissions map[uint8]*network.TransmissionIN
+	cookies       *network.CookieJar               // issues the cookies of the info handshake; nil without acks
+	rateLimiter   *network.RateLimiter             // limits the packets per second of each sender; nil without a packet rate
+	hasher        *network.FileHasher              // hashes existing files to compare them with sent ones (SkipIdentical)
+	usages        map[string]*network.UsageCounter // usage of the directories with a size limit by their path
+	transmissions *network.Registry[network.SessionKey, *network.TransmissionIN]
+	batches       map[network.SessionKey]*network.Batch // received manifests whose files are outstanding
+
+	stats          network.LimitStats // violations of the limits
+	reportedStats  uint64             // total violations when the stats were reported the last time
+	lastStatsCheck time.Time
+
+	output             io.Writer               // receives the single accepted transmission instead of a file (stdout mode)
+	outputTransmission *network.TransmissionIN // the transmission written to output
+	done               chan error              // result of the transmission written to output
 }
 
-func NewReceiver(networkTimeout int, outPath string, addr *net.UDPAddr) (*Receiver, error) {
+func NewReceiver(networkTimeout int, protocol network.Protocol, keyring *network.Keyring, authorizedSenders *network.AuthorizedSenders, limits network.Limits, overwritePolicy network.OverwritePolicy, keepPartial bool, subdirs bool, outPath string, addr *net.UDPAddr) (*Receiver, error) {
 	if networkTimeout < 1 {
 		return nil, errors.New("timeout must be at least 1 second")
 	}
+	if authorizedSenders != nil && !protocol.UsesAcks() {
+		return nil, errors.New("sender authentication requires a protocol with acks")
+	}
+	// without sealed packets the session-id is the only proof that a data-packet comes from the authenticated sender
+	if authorizedSenders != nil && keyring == nil {
+		return nil, errors.New("sender authentication requires a pre-shared key")
+	}
+	if limits.MaxSessionsPerIP < 0 || limits.PacketRate < 0 || limits.PacketBurst < 0 {
+		return nil, errors.New("limits must not be negative")
+	}
 	if addr == nil {
 		return nil, errors.New("addr must not be nil")
 	}
 
+	// without acks the receiver never answers, so it cannot be used for reflection and cookies cannot be sent
+	var cookies *network.CookieJar
+	if protocol.UsesAcks() {
+		var err error
+		cookies, err = network.NewCookieJar()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rateLimiter *network.RateLimiter
+	if limits.PacketRate > 0 {
+		rateLimiter = network.NewRateLimiter(limits.PacketRate, limits.PacketBurst)
+	}
+
 	conn, err := net.ListenUDP("udp", addr)
 	if err != nil {
 		return nil, err
 	}
+	_ = conn.SetReadBuffer(socketBufferSize) // best effort, the kernel may cap the size
 
 	return &Receiver{
 		settings: Settings{
-			networkTimeout: time.Duration(networkTimeout) * time.Second,
+			networkTimeout:    time.Duration(networkTimeout) * time.Second,
+			protocol:          protocol,
+			keyring:           keyring,
+			authorizedSenders: authorizedSenders,
+			limits:            limits,
+			overwritePolicy:   overwritePolicy,
+			keepPartial:       keepPartial,
+			subdirs:           subdirs,
 		},
-		outPath:       outPath,
-		conn:          conn,
-		transmissions: make(map[uint8]*network.TransmissionIN),
+		outPath:        outPath,
+		conn:           conn,
+		cookies:        cookies,
+		rateLimiter:    rateLimiter,
+		hasher:         network.NewFileHasher(),
+		usages:         make(map[string]*network.UsageCounter),
+		transmissions:  network.NewRegistry[network.SessionKey, *network.TransmissionIN](),
+		batches:        make(map[network.SessionKey]*network.Batch),
+		lastStatsCheck: time.Now(),
+		done:           make(chan error, 1),
 	}, nil
 }
 
+// ReceiveTo makes the receiver accept a single transmission and write it to w instead of a file in outPath;
+// must be called before Start
+func (r *Receiver) ReceiveTo(w io.Writer) {
+	r.output = w
+}
+
+// Done returns the result of the transmission that was written to the writer passed to ReceiveTo
+func (r *Receiver) Done() <-chan error {
+	return r.done
+}
+
 func (r *Receiver) Start(status chan error) {
-	r.keepRunning = true
+	atomic.StoreInt32(&r.keepRunning, 1)
 
 	go r.run(status)
 }
 
 func (r *Receiver) run(status chan error) {
-	for r.keepRunning {
+	for atomic.LoadInt32(&r.keepRunning) == 1 {
 		r.closeIdleConnections()
+		r.reportStats(status)
 		msg, addr, err := r.nextUDPMessage()
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			// timeout happened
@@ -79,25 +174,127 @@ func (r *Receiver) run(status chan error) {
 }
 
 func (r *Receiver) Stop() {
-	r.keepRunning = false
+	atomic.StoreInt32(&r.keepRunning, 0)
 }
 
-func (r *Receiver) openNewTransmission(uid uint8) *network.TransmissionIN {
-	newTransmission := network.TransmissionIN{
+// reportStats sends the violations of the limits to status every statsInterval if there were new ones
+func (r *Receiver) reportStats(status chan error) {
+	if time.Since(r.lastStatsCheck) < statsInterval {
+		return
+	}
+	r.lastStatsCheck = time.Now()
+
+	total := r.stats.Total()
+	if total == r.reportedStats {
+		return
+	}
+	r.reportedStats = total
+	status <- fmt.Errorf("limit violations: %s", &r.stats)
+}
+
+// openNewTransmission opens the transmission requested by an info-packet with the uid chosen by the sender;
+// protocols with acks assign a new session-id that the sender has to use after the handshake.
+// salt is the salt of a sealed info-packet; nil without a pre-shared key
+func (r *Receiver) openNewTransmission(clientUid uint32, peer *net.UDPAddr, salt []byte) (*network.TransmissionIN, error) {
+	uid := clientUid
+	if r.settings.protocol.UsesAcks() {
+		var err error
+		uid, err = r.nextSessionID(peer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newTransmission := &network.TransmissionIN{
 		Transmission: network.Transmission{
-			Uid:  uid,
-			Hash: murmur3.New128(),
+			Uid:     uid,
+			Version: packets.MinHeaderVersion, // until negotiated in handleInfo
+			Hash:    murmur3.New128(),
 		},
+		Peer:      peer,
+		ClientUid: clientUid,
+	}
+	if r.settings.keyring != nil {
+		err := r.initKeys(newTransmission, salt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.transmissions.Add(network.NewSessionKey(peer, uid), newTransmission)
+	return newTransmission, nil
+}
+
+// initKeys derives the session keys of a sealed transmission from the salt of the sender and a fresh salt
+func (r *Receiver) initKeys(t *network.TransmissionIN, senderSalt []byte) error {
+	t.Version = packets.SealedHeaderVersion
+	t.Salt = append([]byte(nil), senderSalt...)
+	if r.settings.protocol.UsesAcks() {
+		// sent to the sender in the replies to the info-packet
+		salt, err := network.NewSalt()
+		if err != nil {
+			return err
+		}
+		t.ReceiverSalt = salt
+	}
+
+	keys, err := r.settings.keyring.SessionKeys(t.Salt, t.ReceiverSalt)
+	if err != nil {
+		return err
 	}
-	r.transmissions[uid] = &newTransmission
-	return &newTransmission
+	t.Keys = &keys
+	return nil
+}
+
+// nextSessionID returns a random session-id that is not used by peer; without a pre-shared key it is the only secret
+// of a transmission that a third party has to guess to inject packets into it
+func (r *Receiver) nextSessionID(peer *net.UDPAddr) (uint32, error) {
+	raw := make([]byte, 4)
+	for {
+		_, err := rand.Read(raw)
+		if err != nil {
+			return 0, err
+		}
+		id := binary.LittleEndian.Uint32(raw)
+		if id == 0 {
+			continue
+		}
+		if _, ok := r.transmissions.Get(network.NewSessionKey(peer, id)); !ok {
+			return id, nil
+		}
+	}
+}
+
+// findHandshake returns the transmission that was opened by an info-packet of peer with the given client uid
+func (r *Receiver) findHandshake(peer *net.UDPAddr, clientUid uint32) (*network.TransmissionIN, bool) {
+	var found *network.TransmissionIN
+	r.transmissions.Range(func(_ network.SessionKey, t *network.TransmissionIN) bool {
+		if t.ClientUid == clientUid && t.Peer.String() == peer.String() {
+			found = t
+			return false
+		}
+		return true
+	})
+	return found, found != nil
 }
 
-func (r *Receiver) closeTransmission(uid uint8) {
-	delete(r.transmissions, uid)
+// closeTransmission removes the transmission from the registry and deletes its partial file unless it is kept;
+// the lock of t must be held
+func (r *Receiver) closeTransmission(t *network.TransmissionIN) {
+	if t.Handle != nil && !t.Finished {
+		if r.settings.keepPartial && r.settings.protocol.UsesAcks() {
+			_ = r.saveProgress(t) // allow the sender to resume the transmission
+		}
+		_ = t.Handle.Close()
+		t.Handle = nil
+	}
+	if t.PartPath != "" && !t.Finished && !r.settings.keepPartial {
+		_ = os.Remove(t.PartPath)
+		_ = network.RemoveProgress(t.PartPath)
+	}
+	r.transmissions.Remove(network.NewSessionKey(t.Peer, t.Uid))
 }
 
-func (r *Receiver) nextUDPMessage() (*bytes.Reader, *net.UDPAddr, error) {
+func (r *Receiver) nextUDPMessage() ([]byte, *net.UDPAddr, error) {
 	rawBytes := make([]byte, math.MaxUint16-8)
 
 	// make timeout to be able to react to a Stop() call and not block until next UDPPacket
@@ -111,110 +308,597 @@ func (r *Receiver) nextUDPMessage() (*bytes.Reader, *net.UDPAddr, error) {
 		return nil, nil, err
 	}
 
-	return bytes.NewReader(rawBytes[:n]), addr, nil
+	return rawBytes[:n], addr, nil
 }
 
-func (r *Receiver) handlePacket(udpMessage *bytes.Reader, addr *net.UDPAddr) (err error) {
-	header, err := packets.ParseHeader(udpMessage)
+func (r *Receiver) handlePacket(udpMessage []byte, addr *net.UDPAddr) (err error) {
+	if r.rateLimiter != nil && !r.rateLimiter.Allow(addr.IP, time.Now()) {
+		// dropped without an answer, which would cost the resources the limit saves and could be reflected to a
+		// spoofed address; the sender handles the drops like a loss of packets
+		r.stats.Count(network.PacketRateLimit)
+		return nil
+	}
+
+	var packet packets.Packet
+	var salt []byte
+	if r.settings.keyring == nil {
+		packet, err = packets.Decode(udpMessage)
+	} else {
+		packet, salt, err = r.open(udpMessage, addr)
+	}
 	if err != nil {
 		return err
 	}
+	if packet == nil {
+		return nil //ignore sealed packets of unknown transmissions
+	}
+	header := packet.GetHeader()
 
-	transmission := r.transmissions[header.StreamUID]
-	if transmission == nil {
-		if header.PacketType != packets.Info {
+	var transmission *network.TransmissionIN
+	var ok bool
+	if header.PacketType == packets.Info {
+		if header.SequenceNr != 0 {
+			// a later info-packet would reopen the file of the transmission; only an open one is aborted below
+			transmission, ok = r.findHandshake(addr, header.StreamUID)
+			if !ok {
+				return fmt.Errorf("info-packet of %s with sequence-number %d", addr, header.SequenceNr)
+			}
+		} else if r.cookies != nil && !r.cookies.Verify(addr, header.StreamUID, packet.(*packets.InfoPacket).Cookie) {
+			// no state is created before the sender proved that it receives packets at its address
+			return r.sendCookie(header, addr)
+		} else {
+			// info-packets use the uid chosen by the sender
+			transmission, ok = r.findHandshake(addr, header.StreamUID)
+			if !ok {
+				transmission, err = r.openNewTransmission(header.StreamUID, addr, salt)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		transmission, ok = r.transmissions.Get(network.NewSessionKey(addr, header.StreamUID))
+		if !ok {
 			return nil //ignore unexpected packets (out of order or timed out connections)
 		}
-		transmission = r.openNewTransmission(header.StreamUID)
+		if header.Version != transmission.Version {
+			return nil //ignore packets that do not use the negotiated version
+		}
+	}
+
+	transmission.Lock()
+	defer transmission.Unlock()
+
+	if compressed, ok := packet.(*packets.CompressedDataPacket); ok {
+		packet, err = r.decompress(compressed, transmission)
+		if err != nil {
+			return err
+		}
 	}
 
 	defer func() {
 		transmission.LastUpdated = time.Now()
+		if transmission.Batch != nil {
+			transmission.Batch.LastUpdated = transmission.LastUpdated
+		}
+		if err == nil && transmission.Finished {
+			r.finishOutput(transmission, nil)
+		}
 		if err != nil {
-			//TODO: send error packet
-			r.closeTransmission(transmission.Uid)
+			var limitErr *network.LimitError
+			if errors.As(err, &limitErr) {
+				r.stats.Count(limitErr.Limit)
+			}
+			errorHeader := packets.NewHeader(header.SequenceNr, header.StreamUID, packets.Error)
+			errorHeader.Version = header.Version
+			_ = r.sendError(errorHeader, transmission, network.ErrorCodeOf(err), network.ReasonOf(err), addr)
+			r.finishOutput(transmission, err)
+			r.closeTransmission(transmission)
 		}
 	}()
 
-	switch header.PacketType {
-	case packets.Info:
-		infoPacket, err := packets.ParseInfoPacket(udpMessage)
-		if err != nil {
+	if header.PacketType == packets.Info && header.SequenceNr != 0 {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: info-packet with sequence-number %d", transmission.Uid, header.SequenceNr))
+	}
+
+	if transmission.Finished || header.SequenceNr != transmission.SeqNr {
+		apply, err := r.checkSequence(packet, transmission, addr)
+		if err != nil || !apply {
 			return err
 		}
-		infoPacket.SetHeader(header)
-		err = r.handleInfo(infoPacket, transmission)
+	}
+
+	if _, ok := packet.(*packets.AuthPacket); transmission.Authenticating && !ok {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: packet %d before the auth-packet", transmission.Uid, header.SequenceNr))
+	}
+
+	switch p := packet.(type) {
+	case *packets.InfoPacket:
+		err = r.handleInfo(*p, transmission)
+	case *packets.AuthPacket:
+		err = r.handleAuth(*p, transmission)
+	case *packets.DataPacket:
+		err = r.handleData(*p, transmission)
+	case *packets.FinalizePacket:
+		err = r.handleFinalize(*p, transmission)
+	default:
+		err = network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("unexpected packet with header %v", header))
+	}
+	if errors.Is(err, errNotReady) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !r.settings.protocol.UsesAcks() {
+		return nil
+	}
+
+	err = r.sendAck(header, transmission, addr)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// open authenticates and parses a sealed packet; info-packets are opened with the key derived from their salt and
+// all other packets with the session keys of their transmission. Packets of unknown transmissions are returned as nil
+func (r *Receiver) open(raw []byte, addr *net.UDPAddr) (packets.Packet, []byte, error) {
+	header, salt, err := packets.ParseSealed(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if header.PacketType == packets.Info {
+		if salt == nil {
+			return nil, nil, fmt.Errorf("sealed info-packet of %s with sequence-number %d", addr, header.SequenceNr)
+		}
+		key, err := r.settings.keyring.InfoKey(salt)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		break
-	case packets.Data:
-		dataPacket, err := packets.ParseDataPacket(udpMessage)
+		packet, err := packets.Open(key, raw)
 		if err != nil {
-			return err
+			return nil, nil, fmt.Errorf("info-packet of %s: %w", addr, err)
+		}
+		return packet, salt, nil
+	}
+
+	t, ok := r.transmissions.Get(network.NewSessionKey(addr, header.StreamUID))
+	if !ok {
+		return nil, nil, nil
+	}
+	packet, err := packets.Open(t.Keys.Sender, raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("transmission %d of %s: %w", t.Uid, addr, err)
+	}
+	return packet, nil, nil
+}
+
+// encode serializes a reply to the sender of t and seals it if the receiver has a pre-shared key
+func (r *Receiver) encode(p packets.Packet, t *network.TransmissionIN) []byte {
+	if r.settings.keyring == nil {
+		return packets.Encode(p)
+	}
+	return packets.Seal(t.Keys.Receiver, p, t.ReceiverSalt)
+}
+
+// checkSequence handles a packet whose sequence-number differs from the expected one according to the protocol;
+// returns whether the packet shall be applied nevertheless
+func (r *Receiver) checkSequence(packet packets.Packet, t *network.TransmissionIN, addr *net.UDPAddr) (bool, error) {
+	protocol := r.settings.protocol
+	header := packet.GetHeader()
+
+	if header.SequenceNr < t.SeqNr {
+		// duplicate: acknowledge again but do NOT apply
+		if !protocol.UsesAcks() {
+			return false, nil
+		}
+		if protocol == network.GoBackN {
+			return false, r.sendAck(packets.NewHeader(t.SeqNr-1, header.StreamUID, header.PacketType), t, addr)
+		}
+		return false, r.sendAck(header, t, addr)
+	}
+
+	if t.Finished {
+		return false, network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: unexpected packet %d after finalize", t.Uid, header.SequenceNr))
+	}
+
+	// packet from the future
+	switch protocol {
+	case network.NoControl:
+		// tolerate gaps, the integrity is only checked via the hash
+		t.SeqNr = header.SequenceNr
+		return true, nil
+	case network.GoBackN:
+		// only in-order packets are accepted; repeat the cumulative ack of the last in-order packet
+		return false, r.sendAck(packets.NewHeader(t.SeqNr-1, header.StreamUID, header.PacketType), t, addr)
+	case network.SelectiveRepeat:
+		dataPacket, ok := packet.(*packets.DataPacket)
+		if !ok {
+			return false, nil // only data-packets are buffered
+		}
+		if !t.Buffer(header.SequenceNr, dataPacket.Data) {
+			return false, network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: packet %d outside of the receive window starting at %d", t.Uid, header.SequenceNr, t.SeqNr))
 		}
-		dataPacket.SetHeader(header)
-		err = r.handleData(dataPacket, transmission)
+		return false, r.sendAck(header, t, addr)
+	default:
+		return false, network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: unexpected packet %d; expected %d", t.Uid, header.SequenceNr, t.SeqNr))
+	}
+}
+
+func (r *Receiver) handleInfo(p packets.InfoPacket, t *network.TransmissionIN) error {
+	//TODO: move this to TransmissionIN?
+	err := r.limitSessions(t)
+	if err != nil {
+		return err
+	}
+	if r.settings.protocol.UsesAcks() && r.settings.keyring == nil {
+		// the version is announced to the sender in the ack; without acks both sides stay at MinHeaderVersion
+		// and sealed transmissions always use SealedHeaderVersion
+		version, err := packets.NegotiateVersion(p.MaxVersion)
 		if err != nil {
-			return err
+			return network.NewTransmissionError(packets.CodeUnsupportedVersion, err)
+		}
+		t.Version = version
+	}
+	if p.Compression != uint8(network.NoCompression) {
+		codec, err := network.NewCodec(network.CodecID(p.Compression))
+		if err != nil && !r.settings.protocol.UsesAcks() {
+			// without acks the sender cannot fall back to uncompressed data-packets
+			return network.NewTransmissionError(packets.CodeRejected, err)
+		}
+		if err == nil {
+			// the codec is announced to the sender in the ack
+			t.Compression = network.CodecID(p.Compression)
+			t.Codec = codec
 		}
-		break
-	case packets.Finalize:
-		finalizePacket, err := packets.ParseFinalizePacket(udpMessage)
+	}
+
+	if r.settings.authorizedSenders != nil {
+		return r.challenge(p, t)
+	}
+	return r.accept(p, t)
+}
+
+// limitSessions rejects the transmission if the sender already has the maximum number of unfinished transmissions;
+// senders are identified by their ip-address as they may use any number of ports
+func (r *Receiver) limitSessions(t *network.TransmissionIN) error {
+	if r.settings.limits.MaxSessionsPerIP == 0 {
+		return nil
+	}
+
+	sessions := 0
+	r.transmissions.Range(func(_ network.SessionKey, other *network.TransmissionIN) bool {
+		// packets are handled in a single goroutine, so the other transmissions do not change while they are counted
+		if other != t && !other.Finished && other.Peer.IP.Equal(t.Peer.IP) {
+			sessions++
+		}
+		return true
+	})
+	if sessions >= r.settings.limits.MaxSessionsPerIP {
+		return network.NewLimitError(network.SessionLimit, fmt.Errorf("%s already has %d of %d transmissions", t.Peer.IP, sessions, r.settings.limits.MaxSessionsPerIP))
+	}
+	return nil
+}
+
+// challenge looks up the key of the sender and postpones the info-packet until the sender signed the challenge
+// that is sent in the ack; no file is opened before
+func (r *Receiver) challenge(p packets.InfoPacket, t *network.TransmissionIN) error {
+	if p.Anonymous() {
+		return network.NewTransmissionError(packets.CodeUnauthorized, errors.New("the receiver only accepts authenticated senders"))
+	}
+	sender, ok := r.settings.authorizedSenders.Lookup(p.PublicKey[:])
+	if !ok {
+		return network.NewTransmissionError(packets.CodeUnauthorized, fmt.Errorf("key %s is not authorized", network.FormatPublicKey(p.PublicKey[:])))
+	}
+
+	challenge, err := network.NewChallenge()
+	if err != nil {
+		return err
+	}
+	t.Sender = sender
+	t.Info = p
+	t.Challenge = challenge
+	t.Authenticating = true
+	t.HandshakeSeqNr = 1 // the ack of the auth-packet carries the offset
+	t.SeqNr++
+	return nil
+}
+
+// handleAuth verifies the signature of the challenge and accepts the postponed info-packet
+func (r *Receiver) handleAuth(p packets.AuthPacket, t *network.TransmissionIN) error {
+	if !t.Authenticating {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: unexpected auth-packet", t.Uid))
+	}
+
+	msg := network.AuthMessage(t.Info, t.Uid, t.Challenge, t.Salt, t.ReceiverSalt)
+	if !ed25519.Verify(t.Sender.PublicKey, msg, p.Signature[:]) {
+		return network.NewTransmissionError(packets.CodeUnauthorized, fmt.Errorf("invalid signature of key %s", network.FormatPublicKey(t.Sender.PublicKey)))
+	}
+	// the auth-packet is retransmitted until the usage of the directory of the sender is known
+	err := r.checkUsage(t)
+	if err != nil {
+		return err
+	}
+	t.Authenticating = false
+	return r.accept(t.Info, t)
+}
+
+// accept opens the file, manifest or output requested by the info-packet of an anonymous or authenticated sender
+func (r *Receiver) accept(p packets.InfoPacket, t *network.TransmissionIN) error {
+	t.StartTime = time.Now()
+	t.TotalSize = p.Filesize
+	t.SizeUnknown = p.Stream
+	if r.output != nil {
+		err := r.initOutput(p, t)
 		if err != nil {
 			return err
 		}
-		finalizePacket.SetHeader(header)
-		err = r.handleFinalize(finalizePacket, transmission)
+		t.SeqNr++
+		return nil
+	}
+	if p.Manifest {
+		err := r.initManifest(p, t)
 		if err != nil {
 			return err
 		}
-		break
-	default:
-		return fmt.Errorf("malformed packet with header %v", header)
+		t.SeqNr++
+		return nil
 	}
 
-	err = r.sendAck(header, addr)
+	err := r.checkUsage(t)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	var filePath string
+	if p.Batch != 0 {
+		filePath, err = r.resolveBatchPath(p, t)
+	} else {
+		filePath, err = network.ResolvePath(r.rootOf(t), p.Filename, r.settings.subdirs)
+	}
+	if err != nil {
+		return err
+	}
+	t.Path = filePath
+	if r.claimed(t.Path, t) {
+		if r.settings.overwritePolicy != network.Rename {
+			return network.NewTransmissionError(packets.CodeFileExists, fmt.Errorf("file %q is already being received", p.Filename))
+		}
+		t.Path = r.freePathOf(t.Path, t)
+	}
 
-func (r *Receiver) handleInfo(p packets.InfoPacket, t *network.TransmissionIN) error {
-	//TODO: move this to TransmissionIN?
-	t.StartTime = time.Now()
-	err := r.initFileIO(path.Join(r.outPath, path.Clean(p.Filename)), t)
+	existing, err := os.Stat(t.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	if existing != nil {
+		switch r.settings.overwritePolicy {
+		case network.Overwrite:
+			// the existing file is replaced in commitFile
+		case network.Rename:
+			t.Path = r.freePathOf(t.Path, t)
+		case network.SkipIdentical:
+			// without acks the sender cannot skip the data
+			if existing.Size() == int64(p.Filesize) && !p.Stream && r.settings.protocol.UsesAcks() {
+				err = r.compareFileIO(t)
+				if err != nil {
+					return err
+				}
+				t.SeqNr++
+				return nil
+			}
+			return network.NewTransmissionError(packets.CodeFileExists, fmt.Errorf("a different file already exists at %q", p.Filename))
+		default:
+			return network.NewTransmissionError(packets.CodeFileExists, fmt.Errorf("file already exists at %q", p.Filename))
+		}
+	}
+	t.PartPath = partPathOf(t.Path)
+
+	resumed := false
+	if p.Resume && !p.Stream && r.settings.protocol.UsesAcks() {
+		// the offset is announced to the sender in the ack
+		var err error
+		resumed, err = r.resumeFileIO(t)
+		if err != nil {
+			return err
+		}
+	}
+	if !resumed {
+		err := r.initFileIO(t)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.limitWrites(t)
 	if err != nil {
 		return err
 	}
 
-	t.TotalSize = p.Filesize
 	t.SeqNr++
 	return nil
 }
 
+// rootOf returns the directory the files of the sender of t are stored in
+func (r *Receiver) rootOf(t *network.TransmissionIN) string {
+	if t.Sender != nil {
+		return t.Sender.Dir
+	}
+	return r.outPath
+}
+
+// limitWrites limits the writes of a file to the space left within the size limits of the receiver and the quota of
+// the sender; the outstanding data of the other limited transmissions is reserved
+func (r *Receiver) limitWrites(t *network.TransmissionIN) error {
+	limits := r.settings.limits
+	if limits.MaxFileSize != 0 && !t.SizeUnknown && t.TotalSize > limits.MaxFileSize {
+		return network.NewLimitError(network.FileSizeLimit, fmt.Errorf("file of %d bytes exceeds the maximum of %d bytes", t.TotalSize, limits.MaxFileSize))
+	}
+
+	restrict := func(limit network.Limit, max uint64, used uint64) {
+		left := uint64(0)
+		if used < max {
+			left = max - used
+		}
+		if !t.WriteLimited || left < t.WriteLeft {
+			t.WriteLimited = true
+			t.WriteLeft = left
+			t.WriteLimit = limit
+		}
+	}
+	if limits.MaxFileSize != 0 {
+		restrict(network.FileSizeLimit, limits.MaxFileSize, t.Offset)
+	}
+	if limits.MaxTotalSize != 0 {
+		restrict(network.TotalSizeLimit, limits.MaxTotalSize, r.usageOf(r.outPath, t, nil))
+	}
+	if t.Sender != nil && t.Sender.Quota != 0 {
+		restrict(network.SenderQuotaLimit, t.Sender.Quota, r.usageOf(t.Sender.Dir, t, t.Sender))
+	}
+
+	if t.WriteLimited && !t.SizeUnknown && t.TotalSize-t.Offset > t.WriteLeft {
+		return network.NewLimitError(t.WriteLimit, fmt.Errorf("file of %d bytes exceeds the %d bytes left within the %s limit", t.TotalSize-t.Offset, t.WriteLeft, t.WriteLimit))
+	}
+	return nil
+}
+
+// checkUsage returns errNotReady until the usage of the directories whose size is limited for t is known; the
+// directories are walked in the background
+func (r *Receiver) checkUsage(t *network.TransmissionIN) error {
+	dirs := make([]string, 0, 2)
+	if r.settings.limits.MaxTotalSize != 0 {
+		dirs = append(dirs, r.outPath)
+	}
+	if t.Sender != nil && t.Sender.Quota != 0 {
+		dirs = append(dirs, t.Sender.Dir)
+	}
+
+	ready := true
+	for _, dir := range dirs {
+		counter, ok := r.usages[dir]
+		if !ok {
+			counter = network.NewUsageCounter(dir)
+			r.usages[dir] = counter
+		}
+		_, known, err := counter.Usage(time.Now())
+		if err != nil {
+			return network.NewTransmissionError(packets.CodeIOError, err)
+		}
+		ready = ready && known
+	}
+	if !ready {
+		return errNotReady
+	}
+	return nil
+}
+
+// usageOf returns the size of the files in dir, including partial files and the resumed part of t, plus the
+// outstanding data of the other limited transmissions; only those of sender if it is not nil. The usage of dir must
+// be known (checkUsage)
+func (r *Receiver) usageOf(dir string, t *network.TransmissionIN, sender *network.AuthorizedSender) uint64 {
+	usage, _, _ := r.usages[dir].Usage(time.Now())
+	r.transmissions.Range(func(_ network.SessionKey, other *network.TransmissionIN) bool {
+		if other == t || !other.WriteLimited || other.SizeUnknown || other.TotalSize <= other.TransmittedSize {
+			return true
+		}
+		if sender == nil || other.Sender == sender {
+			usage += other.TotalSize - other.TransmittedSize
+		}
+		return true
+	})
+	return usage
+}
+
 func (r *Receiver) handleData(p packets.DataPacket, t *network.TransmissionIN) error {
-	//TODO: move this to TransmissionIN?
-	_, err := t.File.Write(p.Data)
+	if t.Existing != nil {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: unexpected data for an existing file", t.Uid))
+	}
+	err := r.checkResumed(t)
 	if err != nil {
 		return err
 	}
 
-	_, err = t.Hash.Write(p.Data)
+	err = r.writeData(p.Data, t)
 	if err != nil {
 		return err
 	}
 
-	t.TransmittedSize += uint64(len(p.Data))
+	// write out-of-order packets that became in-order
+	for data, ok := t.NextBuffered(); ok; data, ok = t.NextBuffered() {
+		err = r.writeData(data, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.settings.protocol.UsesAcks() && t.PartPath != "" && t.TransmittedSize-t.SavedSize >= progressInterval {
+		return r.saveProgress(t)
+	}
+	return nil
+}
+
+func (r *Receiver) writeData(data []byte, t *network.TransmissionIN) error {
+	if !t.SizeUnknown && uint64(len(data)) > t.TotalSize-t.TransmittedSize {
+		// the announced size is used to reserve space, so it must not be exceeded
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d exceeds the announced size of %d bytes", t.Uid, t.TotalSize))
+	}
+	if t.WriteLimited {
+		if uint64(len(data)) > t.WriteLeft {
+			return network.NewLimitError(t.WriteLimit, fmt.Errorf("transmission %d exceeds the %s limit", t.Uid, t.WriteLimit))
+		}
+		t.WriteLeft -= uint64(len(data))
+	}
+
+	_, err := t.File.Write(data)
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	if t.PartPath != "" {
+		r.countWritten(t, uint64(len(data)))
+	}
+
+	_, err = t.Hash.Write(data)
+	if err != nil {
+		return err
+	}
+
+	t.TransmittedSize += uint64(len(data))
 	t.SeqNr++
 	return nil
 }
 
+// countWritten adds n bytes written to the file of t to the usage of the directories it is stored in
+func (r *Receiver) countWritten(t *network.TransmissionIN, n uint64) {
+	if counter, ok := r.usages[r.outPath]; ok {
+		counter.Add(n)
+	}
+	if t.Sender != nil && t.Sender.Dir != r.outPath {
+		if counter, ok := r.usages[t.Sender.Dir]; ok {
+			counter.Add(n)
+		}
+	}
+}
+
 func (r *Receiver) handleFinalize(p packets.FinalizePacket, t *network.TransmissionIN) error {
 	//TODO: move this to TransmissionIN?
-	_ = t.File.Flush()
+	if t.Existing != nil {
+		return r.handleFinalizeExisting(p, t)
+	}
+	if t.Manifest != nil {
+		return r.handleFinalizeManifest(p, t)
+	}
+	err := r.checkResumed(t)
+	if err != nil {
+		return err
+	}
+
+	err = t.File.Flush()
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
 
 	actualHash := make([]byte, 0)
 	actualHash = t.Hash.Sum(actualHash)
@@ -223,10 +907,44 @@ func (r *Receiver) handleFinalize(p packets.FinalizePacket, t *network.Transmiss
 
 	diff := bytes.Compare(actualHash, expectedHash)
 	if diff != 0 {
-		return fmt.Errorf("integrity check failed; expected:<%x> actual:<%x>", expectedHash, actualHash)
+		// the received data is corrupt, resuming the transmission would fail again
+		if t.Handle != nil {
+			_ = t.Handle.Close()
+			t.Handle = nil
+			_ = network.RemoveProgress(t.PartPath)
+		}
+		return network.NewTransmissionError(packets.CodeChecksumMismatch, fmt.Errorf("integrity check failed; expected:<%x> actual:<%x>", expectedHash, actualHash))
+	}
+
+	if t.SizeUnknown {
+		t.TotalSize = t.TransmittedSize
+		t.SizeUnknown = false
+	}
+
+	if t == r.outputTransmission {
+		t.Finished = true // reported by handlePacket once acknowledged
+		t.SeqNr++
+		return nil
+	}
+
+	if t.Batch != nil && !bytes.Equal(expectedHash, t.Entry.Hash[:]) {
+		return network.NewTransmissionError(packets.CodeChecksumMismatch, fmt.Errorf("file %q does not match the manifest", t.Entry.Path))
 	}
 
-	r.closeTransmission(t.Uid)
+	err = r.commitFile(t)
+	if err != nil {
+		return err
+	}
+	if t.Batch != nil {
+		// restore the metadata of the manifest; the data is verified already
+		_ = os.Chmod(t.Path, t.Entry.Mode.Perm())
+		_ = os.Chtimes(t.Path, t.Entry.ModTime, t.Entry.ModTime)
+		r.markReceived(t)
+	}
+
+	// keep the transmission until it times out to acknowledge retransmitted finalize-packets
+	t.Finished = true
+	t.SeqNr++
 
 	// PRINTING
 	_, _ = fmt.Fprintf(measureLog, "%d\n", time.Since(t.StartTime).Milliseconds())
@@ -234,10 +952,113 @@ func (r *Receiver) handleFinalize(p packets.FinalizePacket, t *network.Transmiss
 	return nil
 }
 
-func (r *Receiver) sendAck(header packets.Header, addr *net.UDPAddr) error {
+// handleFinalizeExisting keeps the existing file if it is identical to the sent one (SkipIdentical)
+func (r *Receiver) handleFinalizeExisting(p packets.FinalizePacket, t *network.TransmissionIN) error {
+	if !t.Existing.Done() {
+		return errNotReady
+	}
+	_, err := t.Existing.Result()
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+
+	actualHash := t.Existing.Sum()
+	if !bytes.Equal(actualHash, p.Checksum[:]) {
+		return network.NewTransmissionError(packets.CodeFileExists, fmt.Errorf("a different file already exists at %q", path.Base(t.Path)))
+	}
+	if t.Batch != nil {
+		if !bytes.Equal(actualHash, t.Entry.Hash[:]) {
+			return network.NewTransmissionError(packets.CodeChecksumMismatch, fmt.Errorf("file %q does not match the manifest", t.Entry.Path))
+		}
+		r.markReceived(t)
+	}
+
+	t.Finished = true
+	t.SeqNr++
+	return nil
+}
+
+// handleFinalizeManifest verifies the received manifest and creates its directories;
+// the files of the manifest are expected afterwards in separate transmissions
+func (r *Receiver) handleFinalizeManifest(p packets.FinalizePacket, t *network.TransmissionIN) error {
+	err := t.File.Flush()
+	if err != nil {
+		return err
+	}
+
+	actualHash := t.Hash.Sum(nil)
+	if !bytes.Equal(actualHash, p.Checksum[:]) {
+		return network.NewTransmissionError(packets.CodeChecksumMismatch, fmt.Errorf("integrity check of the manifest failed; expected:<%x> actual:<%x>", p.Checksum, actualHash))
+	}
+
+	manifest, err := network.ParseManifest(t.Manifest.Bytes())
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, err)
+	}
+	for _, entry := range manifest.Entries {
+		if entry.Mode.IsDir() {
+			_, err = network.ResolveDir(t.Path, entry.Path)
+		} else {
+			_, err = network.SplitName(entry.Path, true)
+		}
+		if err != nil {
+			return network.NewTransmissionError(packets.CodeRejected, err)
+		}
+	}
+
+	batch := network.NewBatch(network.NewSessionKey(t.Peer, t.Uid), manifest, t.Path)
+	batch.Sender = t.Sender
+	r.batches[batch.Key] = batch
+	if batch.Complete() {
+		r.completeBatch(batch) // no files
+	}
+
+	t.Manifest = nil
+	t.Finished = true
+	t.SeqNr++
+	return nil
+}
+
+func (r *Receiver) sendAck(header packets.Header, t *network.TransmissionIN, addr *net.UDPAddr) error {
 	//TODO: move this to TransmissionIN?
-	header.PacketType = packets.Ack
-	_, _, err := r.conn.WriteMsgUDP(header.ToBytes(), nil, addr)
+	header.Version = t.Version
+	// all acks of a sequence-number must carry the same fields, as they are sealed with the same nonce
+	offset := uint64(0)
+	if header.SequenceNr >= t.HandshakeSeqNr {
+		offset = t.Offset // the sender continues the file at the offset
+	}
+	ack := packets.NewAckPacket(t.Uid, offset, uint8(t.Compression), t.Challenge)
+	ack.SetHeader(header)
+	_, _, err := r.conn.WriteMsgUDP(r.encode(&ack, t), nil, addr)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sendCookie answers an info-packet without a valid cookie; the cookie-packet is smaller than the info-packet to
+// not amplify packets sent with a spoofed address
+func (r *Receiver) sendCookie(header packets.Header, addr *net.UDPAddr) error {
+	cookie := packets.NewCookiePacket(r.cookies.Issue(addr, header.StreamUID))
+	cookie.SetHeader(packets.NewHeader(header.SequenceNr, header.StreamUID, packets.Cookie))
+	_, _, err := r.conn.WriteMsgUDP(packets.Encode(&cookie), nil, addr)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sendError notifies the sender that its transmission was aborted; no control messages are sent without acks (V1)
+func (r *Receiver) sendError(header packets.Header, t *network.TransmissionIN, code packets.ErrorCode, reason string, addr *net.UDPAddr) error {
+	if !r.settings.protocol.UsesAcks() {
+		return nil
+	}
+
+	errorPacket := packets.NewErrorPacket(code, reason)
+	errorPacket.SetHeader(header)
+	_, _, err := r.conn.WriteMsgUDP(r.encode(&errorPacket, t), nil, addr)
 	if err != nil {
 		return err
 	}
@@ -246,33 +1067,306 @@ func (r *Receiver) sendAck(header packets.Header, addr *net.UDPAddr) error {
 }
 
 func (r *Receiver) closeIdleConnections() {
-	for i := 0; i < 256; i++ {
-		uid := uint8(i)
-		curTransmission := r.transmissions[uid]
-		if curTransmission == nil {
-			continue
+	for key, batch := range r.batches {
+		if time.Now().After(batch.LastUpdated.Add(r.settings.networkTimeout)) {
+			_, _ = fmt.Fprintf(errorLog, "batch %q of %s is incomplete: %d of %d files are missing\n", path.Base(batch.Dir), key.Peer, batch.Missing(), len(batch.Manifest.Files()))
+			delete(r.batches, key)
 		}
+	}
+
+	r.transmissions.Range(func(_ network.SessionKey, curTransmission *network.TransmissionIN) bool {
+		curTransmission.Lock()
+		defer curTransmission.Unlock()
+
 		if time.Now().After(curTransmission.LastUpdated.Add(r.settings.networkTimeout)) {
-			_, _ = fmt.Fprintf(errorLog, "transmission %d timed out\n", i)
-			r.closeTransmission(uid)
+			if curTransmission.Finished {
+				r.closeTransmission(curTransmission)
+				return true
+			}
+			_, _ = fmt.Fprintf(errorLog, "transmission %d of %s timed out\n", curTransmission.Uid, curTransmission.Peer)
+			errorHeader := packets.NewHeader(curTransmission.SeqNr, curTransmission.Uid, packets.Error)
+			errorHeader.Version = curTransmission.Version
+			_ = r.sendError(errorHeader, curTransmission, packets.CodeTimeout, "transmission timed out", curTransmission.Peer)
+			r.finishOutput(curTransmission, fmt.Errorf("transmission %d of %s timed out", curTransmission.Uid, curTransmission.Peer))
+			r.closeTransmission(curTransmission)
 		}
+		return true
+	})
+}
+
+// initFileIO creates the partial file of the transmission; a partial file of a previous transmission is replaced
+func (r *Receiver) initFileIO(t *network.TransmissionIN) error {
+	file, err := os.Create(t.PartPath)
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
 	}
+	_ = network.RemoveProgress(t.PartPath) // progress of a previous transmission is no longer valid
+
+	t.Handle = file
+	t.File = bufio.NewWriterSize(file, math.MaxUint16-8)
+	return nil
 }
 
-func (r *Receiver) initFileIO(filePath string, t *network.TransmissionIN) error {
-	_, err := os.Open(filePath)
-	if os.IsExist(err) {
-		return errors.New("file already exists at specified path")
+// resumeFileIO continues the partial file of the transmission if its progress matches the transmission;
+// returns false if there is nothing to resume and the transmission has to start over
+func (r *Receiver) resumeFileIO(t *network.TransmissionIN) (bool, error) {
+	progress, err := network.LoadProgress(t.PartPath)
+	if err != nil || progress.TotalSize != t.TotalSize || progress.Offset > t.TotalSize {
+		return false, nil
 	}
-	if err != nil && !os.IsNotExist(err) {
-		return err
+
+	file, err := os.OpenFile(t.PartPath, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return false, network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	if stat.Size() < int64(progress.Offset) {
+		_ = file.Close()
+		return false, nil
 	}
 
-	file, err := os.Create(filePath)
+	// data behind the offset may be incomplete
+	err = file.Truncate(int64(progress.Offset))
 	if err != nil {
-		return err
+		_ = file.Close()
+		return false, network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	_, err = file.Seek(int64(progress.Offset), io.SeekStart)
+	if err != nil {
+		_ = file.Close()
+		return false, network.NewTransmissionError(packets.CodeIOError, err)
 	}
 
+	t.Handle = file
 	t.File = bufio.NewWriterSize(file, math.MaxUint16-8)
+	t.Offset = progress.Offset
+	t.TransmittedSize = progress.Offset
+	t.SavedSize = progress.Offset
+	// the hash of the already received data is restored in the background, the data behind it waits for it
+	t.Resuming = network.StartHash(t.Hash, io.NewSectionReader(file, 0, int64(progress.Offset)))
+	return true, nil
+}
+
+// checkResumed returns errNotReady until the hash of the resumed part of the file is restored
+func (r *Receiver) checkResumed(t *network.TransmissionIN) error {
+	if t.Resuming == nil {
+		return nil
+	}
+	if !t.Resuming.Done() {
+		return errNotReady
+	}
+	size, err := t.Resuming.Result()
+	t.Resuming = nil
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	if uint64(size) != t.Offset {
+		return network.NewTransmissionError(packets.CodeIOError, fmt.Errorf("transmission %d: read %d of the %d resumed bytes", t.Uid, size, t.Offset))
+	}
+	return nil
+}
+
+// decompress turns a compressed data-packet into a plain one with the same header
+func (r *Receiver) decompress(p *packets.CompressedDataPacket, t *network.TransmissionIN) (packets.Packet, error) {
+	if t.Codec == nil {
+		return nil, network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: compressed data-packet without negotiated compression", t.Uid))
+	}
+	data, err := t.Codec.Decompress(p.Data, math.MaxUint16)
+	if err != nil {
+		return nil, network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("transmission %d: %w", t.Uid, err))
+	}
+
+	plain := packets.NewDataPacket(data)
+	plain.SetHeader(p.GetHeader())
+	return &plain, nil
+}
+
+// initOutput writes the transmission to the output of the receiver; only a single file or stream is accepted
+func (r *Receiver) initOutput(p packets.InfoPacket, t *network.TransmissionIN) error {
+	if r.outputTransmission != nil {
+		return network.NewTransmissionError(packets.CodeRejected, errors.New("the receiver only accepts a single transmission"))
+	}
+	if p.Manifest || p.Batch != 0 {
+		return network.NewTransmissionError(packets.CodeRejected, errors.New("directories cannot be written to the output of the receiver"))
+	}
+
+	r.outputTransmission = t
+	t.File = bufio.NewWriterSize(r.output, math.MaxUint16-8)
+	return nil
+}
+
+// finishOutput reports the result of t if it is written to the output of the receiver
+func (r *Receiver) finishOutput(t *network.TransmissionIN, err error) {
+	if t != r.outputTransmission {
+		return
+	}
+	select {
+	case r.done <- err:
+	default: // already reported
+	}
+}
+
+// initManifest prepares receiving the manifest of the directory p.Filename in memory
+func (r *Receiver) initManifest(p packets.InfoPacket, t *network.TransmissionIN) error {
+	if p.Filesize > network.MaxManifestSize {
+		return network.NewTransmissionError(packets.CodeRejected, fmt.Errorf("manifest of %d bytes exceeds the maximum of %d bytes", p.Filesize, network.MaxManifestSize))
+	}
+	_, err := network.SplitName(p.Filename, false)
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeRejected, err)
+	}
+	dir, err := network.ResolveDir(r.rootOf(t), p.Filename)
+	if err != nil {
+		return err
+	}
+
+	t.Path = dir
+	t.Manifest = &bytes.Buffer{}
+	t.File = bufio.NewWriter(t.Manifest)
+	return nil
+}
+
+// resolveBatchPath returns the path of a file of the batch announced in p; files that are not listed are rejected
+func (r *Receiver) resolveBatchPath(p packets.InfoPacket, t *network.TransmissionIN) (string, error) {
+	batch, ok := r.batches[network.NewSessionKey(t.Peer, p.Batch)]
+	if !ok || batch.Sender != t.Sender {
+		return "", network.NewTransmissionError(packets.CodeRejected, fmt.Errorf("unknown batch %d", p.Batch))
+	}
+	entry, ok := batch.Entry(p.Filename)
+	if !ok || entry.Size != p.Filesize {
+		return "", network.NewTransmissionError(packets.CodeRejected, fmt.Errorf("file %q is not outstanding in batch %d", p.Filename, p.Batch))
+	}
+
+	t.Batch = batch
+	t.Entry = entry
+	return network.ResolvePath(batch.Dir, p.Filename, true)
+}
+
+// markReceived completes the file of t in its batch
+func (r *Receiver) markReceived(t *network.TransmissionIN) {
+	t.Batch.MarkReceived(t.Entry.Path)
+	if t.Batch.Complete() {
+		r.completeBatch(t.Batch)
+	}
+}
+
+// completeBatch restores the metadata of the directories of a completely received batch and forgets it
+func (r *Receiver) completeBatch(batch *network.Batch) {
+	for _, dir := range batch.Manifest.SortedDirs() {
+		dirPath := path.Join(batch.Dir, dir.Path)
+		_ = os.Chmod(dirPath, dir.Mode.Perm())
+		_ = os.Chtimes(dirPath, dir.ModTime, dir.ModTime)
+	}
+	delete(r.batches, batch.Key)
+}
+
+// compareFileIO hashes the existing file of the transmission in the background and announces its full size as offset;
+// the sender skips all data and only sends the finalize-packet to compare the hashes (SkipIdentical)
+func (r *Receiver) compareFileIO(t *network.TransmissionIN) error {
+	existing, err := r.hasher.Hash(t.Path)
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+
+	t.Existing = existing
+	t.Offset = uint64(existing.Size)
+	t.TransmittedSize = uint64(existing.Size)
+	return nil
+}
+
+// commitFile syncs the verified partial file to disk and renames it to the path of the received file
+func (r *Receiver) commitFile(t *network.TransmissionIN) error {
+	err := t.Handle.Sync()
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	err = t.Handle.Close()
+	t.Handle = nil
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+
+	// another transmission may have created the file in the meantime
+	_, err = os.Stat(t.Path)
+	if err == nil {
+		switch r.settings.overwritePolicy {
+		case network.Overwrite:
+			// replaced by the rename
+		case network.Rename:
+			t.Path = r.freePathOf(t.Path, t)
+		default:
+			return network.NewTransmissionError(packets.CodeFileExists, fmt.Errorf("file already exists at %q", path.Base(t.Path)))
+		}
+	}
+
+	err = os.Rename(t.PartPath, t.Path)
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	_ = network.RemoveProgress(t.PartPath)
+
+	// persist the rename; not supported by every platform
+	dir, err := os.Open(path.Dir(t.Path))
+	if err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
+
+// freePathOf returns filePath with the lowest numeric suffix that neither exists nor is claimed by another
+// transmission than t, e.g. "dir/file_1.txt"
+func (r *Receiver) freePathOf(filePath string, t *network.TransmissionIN) string {
+	ext := path.Ext(filePath)
+	stem := strings.TrimSuffix(filePath, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", stem, i, ext)
+		_, err := os.Stat(candidate)
+		if os.IsNotExist(err) && !r.claimed(candidate, t) {
+			return candidate
+		}
+	}
+}
+
+// claimed reports whether another transmission than t is receiving the file at filePath; the partial file only
+// depends on the path of the file, so it must not be shared
+func (r *Receiver) claimed(filePath string, t *network.TransmissionIN) bool {
+	partPath := partPathOf(filePath)
+	found := false
+	r.transmissions.Range(func(_ network.SessionKey, other *network.TransmissionIN) bool {
+		// packets are handled in a single goroutine, so the other transmissions do not change while they are checked
+		if other != t && !other.Finished && other.PartPath == partPath {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// partPathOf returns the path of the hidden file that is written until the file at filePath is received completely
+func partPathOf(filePath string) string {
+	return path.Join(path.Dir(filePath), "."+path.Base(filePath)+network.PartSuffix)
+}
+
+// saveProgress writes out the received data and persists the progress to be able to resume the transmission
+func (r *Receiver) saveProgress(t *network.TransmissionIN) error {
+	err := t.File.Flush()
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+
+	err = network.SaveProgress(t.PartPath, network.Progress{Offset: t.TransmittedSize, TotalSize: t.TotalSize})
+	if err != nil {
+		return network.NewTransmissionError(packets.CodeIOError, err)
+	}
+	t.SavedSize = t.TransmittedSize
 	return nil
 }
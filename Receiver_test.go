@@ -0,0 +1,341 @@
+package main
+
+import (
+	"github.com/twmb/murmur3"
+	"net"
+	"os"
+	"path/filepath"
+	"satae66.dev/netzeps2022/network"
+	"satae66.dev/netzeps2022/network/packets"
+	"testing"
+	"time"
+)
+
+// newTestReceiver starts a receiver on a loopback port that stores files in a temporary directory
+func newTestReceiver(t *testing.T, protocol network.Protocol) (*Receiver, string) {
+	t.Helper()
+	outPath := t.TempDir()
+	r, err := NewReceiver(1, protocol, nil, nil, network.Limits{}, network.Refuse, false, false, outPath, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start(make(chan error, 100))
+	t.Cleanup(func() {
+		r.Stop()
+		_ = r.conn.Close()
+	})
+	return r, outPath
+}
+
+// newTestSender opens the socket of a sender on a loopback port
+func newTestSender(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+// exchange sends p from conn to the receiver and returns the reply; p is retransmitted like by a sender if the
+// receiver drops it
+func exchange(t *testing.T, conn *net.UDPConn, r *Receiver, p packets.Packet) packets.Packet {
+	t.Helper()
+	buf := make([]byte, 1500)
+	for retries := 0; retries < 10; retries++ {
+		_, err := conn.WriteToUDP(packets.Encode(p), r.conn.LocalAddr().(*net.UDPAddr))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := packets.Decode(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+	t.Fatalf("no reply to the packet with header %v", p.GetHeader())
+	return nil
+}
+
+// newTestInfo creates the info-packet of a file with the given name and size
+func newTestInfo(name string, size int) *packets.InfoPacket {
+	info := packets.NewInfoPacket(uint64(size), packets.MaxHeaderVersion, false, name)
+	info.SetHeader(packets.NewHeader(0, 42, packets.Info))
+	return &info
+}
+
+// handshake opens the transmission announced by info and returns the ack of the info-packet
+func handshake(t *testing.T, conn *net.UDPConn, r *Receiver, info *packets.InfoPacket) *packets.AckPacket {
+	t.Helper()
+	cookie, ok := exchange(t, conn, r, info).(*packets.CookiePacket)
+	if !ok {
+		t.Fatal("the first info-packet was not answered with a cookie-packet")
+	}
+	info.Cookie = cookie.Cookie
+	ack, ok := exchange(t, conn, r, info).(*packets.AckPacket)
+	if !ok {
+		t.Fatal("the info-packet with the cookie was not acknowledged")
+	}
+	return ack
+}
+
+// sendData sends the data-packet with the given sequence-number and returns the sequence-number of the ack
+func sendData(t *testing.T, conn *net.UDPConn, r *Receiver, ack *packets.AckPacket, seqNr uint32, data string) uint32 {
+	t.Helper()
+	p := packets.NewDataPacket([]byte(data))
+	header := packets.NewHeader(seqNr, ack.SessionID, packets.Data)
+	header.Version = ack.Version
+	p.SetHeader(header)
+
+	reply, ok := exchange(t, conn, r, &p).(*packets.AckPacket)
+	if !ok {
+		t.Fatalf("data-packet %d was not acknowledged", seqNr)
+	}
+	return reply.SequenceNr
+}
+
+// sendFinalize sends the finalize-packet with the hash of data and fails unless it is acknowledged
+func sendFinalize(t *testing.T, conn *net.UDPConn, r *Receiver, ack *packets.AckPacket, seqNr uint32, data string) {
+	t.Helper()
+	hash := murmur3.New128()
+	_, _ = hash.Write([]byte(data))
+	checksum := [16]byte{}
+	copy(checksum[:], hash.Sum(nil))
+
+	p := packets.NewFinalizePacket(checksum)
+	header := packets.NewHeader(seqNr, ack.SessionID, packets.Finalize)
+	header.Version = ack.Version
+	p.SetHeader(header)
+
+	reply := exchange(t, conn, r, &p)
+	if _, ok := reply.(*packets.AckPacket); !ok {
+		t.Fatalf("finalize-packet was answered with %+v; want an ack-packet", reply)
+	}
+}
+
+func assertFile(t *testing.T, filePath string, want string) {
+	t.Helper()
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("received %q; want %q", got, want)
+	}
+}
+
+func TestDuplicateDataIsAcknowledgedButNotWritten(t *testing.T) {
+	r, outPath := newTestReceiver(t, network.StopAndWait)
+	conn := newTestSender(t)
+
+	ack := handshake(t, conn, r, newTestInfo("file.txt", len("hello world")))
+	for _, step := range []struct {
+		seqNr uint32
+		data  string
+	}{
+		{1, "hello "},
+		{1, "hello "}, // retransmitted after a lost ack
+		{2, "world"},
+	} {
+		if acked := sendData(t, conn, r, ack, step.seqNr, step.data); acked != step.seqNr {
+			t.Errorf("data-packet %d acknowledged as %d", step.seqNr, acked)
+		}
+	}
+	sendFinalize(t, conn, r, ack, 3, "hello world")
+	assertFile(t, filepath.Join(outPath, "file.txt"), "hello world")
+}
+
+func TestResumeRestoresHash(t *testing.T) {
+	r, outPath := newTestReceiver(t, network.StopAndWait)
+	conn := newTestSender(t)
+
+	partPath := partPathOf(filepath.Join(outPath, "file.txt"))
+	err := os.WriteFile(partPath, []byte("hello garbage"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = network.SaveProgress(partPath, network.Progress{Offset: uint64(len("hello ")), TotalSize: uint64(len("hello world"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := newTestInfo("file.txt", len("hello world"))
+	info.Resume = true
+	ack := handshake(t, conn, r, info)
+	if ack.Offset != uint64(len("hello ")) {
+		t.Fatalf("resumed at offset %d; want %d", ack.Offset, len("hello "))
+	}
+	sendData(t, conn, r, ack, 1, "world")
+	sendFinalize(t, conn, r, ack, 2, "hello world")
+	assertFile(t, filepath.Join(outPath, "file.txt"), "hello world")
+}
+
+func TestReorderedData(t *testing.T) {
+	tests := []struct {
+		protocol network.Protocol
+		order    []uint32 // sequence-numbers of the data-packets in the order they arrive
+		acks     []uint32 // expected sequence-numbers of the acks
+	}{
+		// only in-order packets are accepted; the last in-order packet is acknowledged again
+		{protocol: network.GoBackN, order: []uint32{2, 1, 2}, acks: []uint32{0, 1, 2}},
+		// out-of-order packets are buffered and written once the gap is filled
+		{protocol: network.SelectiveRepeat, order: []uint32{3, 2, 1}, acks: []uint32{3, 2, 1}},
+	}
+	chunks := map[uint32]string{1: "hello ", 2: "reordered ", 3: "world"}
+	for _, test := range tests {
+		t.Run(test.protocol.String(), func(t *testing.T) {
+			r, outPath := newTestReceiver(t, test.protocol)
+			conn := newTestSender(t)
+
+			data := ""
+			for seqNr := uint32(1); seqNr <= maxOf(test.order); seqNr++ {
+				data += chunks[seqNr]
+			}
+			ack := handshake(t, conn, r, newTestInfo("file.txt", len(data)))
+			for i, seqNr := range test.order {
+				if acked := sendData(t, conn, r, ack, seqNr, chunks[seqNr]); acked != test.acks[i] {
+					t.Errorf("data-packet %d acknowledged as %d; want %d", seqNr, acked, test.acks[i])
+				}
+			}
+			sendFinalize(t, conn, r, ack, maxOf(test.order)+1, data)
+			assertFile(t, filepath.Join(outPath, "file.txt"), data)
+		})
+	}
+}
+
+func maxOf(values []uint32) uint32 {
+	max := uint32(0)
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+func TestSpoofedInfoPacketsCreateNoFiles(t *testing.T) {
+	r, outPath := newTestReceiver(t, network.StopAndWait)
+	conn := newTestSender(t)
+	self := conn.LocalAddr().(*net.UDPAddr)
+
+	const uid = 42
+	newInfo := func(cookie [packets.CookieSize]byte) *packets.InfoPacket {
+		info := packets.NewInfoPacket(4, packets.MaxHeaderVersion, false, "spoofed.txt")
+		info.SetHeader(packets.NewHeader(0, uid, packets.Info))
+		info.Cookie = cookie
+		return &info
+	}
+
+	forged := [packets.CookieSize]byte{}
+	copy(forged[:], "forged cookie!!!")
+	cookies := map[string][packets.CookieSize]byte{
+		"no cookie":                  {},
+		"forged cookie":              forged,
+		"cookie of another port":     r.cookies.Issue(&net.UDPAddr{IP: self.IP, Port: self.Port + 1}, uid),
+		"cookie of another ip":       r.cookies.Issue(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: self.Port}, uid),
+		"cookie of another uid":      r.cookies.Issue(self, uid+1),
+		"cookie of another receiver": mustCookieJar(t).Issue(self, uid),
+	}
+	for name, cookie := range cookies {
+		reply := exchange(t, conn, r, newInfo(cookie))
+		if _, ok := reply.(*packets.CookiePacket); !ok {
+			t.Errorf("%s: got %T; want a cookie-packet", name, reply)
+		}
+		if n := r.transmissions.Len(); n != 0 {
+			t.Errorf("%s: %d transmissions; want none", name, n)
+		}
+	}
+
+	entries, err := os.ReadDir(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files in the output directory; want none", len(entries))
+	}
+
+	// a sender that echoes the cookie it received at its address is accepted
+	reply := exchange(t, conn, r, newInfo(r.cookies.Issue(self, uid)))
+	if _, ok := reply.(*packets.AckPacket); !ok {
+		t.Errorf("valid cookie: got %T; want an ack-packet", reply)
+	}
+	if n := r.transmissions.Len(); n != 1 {
+		t.Errorf("valid cookie: %d transmissions; want 1", n)
+	}
+}
+
+func mustCookieJar(t *testing.T) *network.CookieJar {
+	t.Helper()
+	jar, err := network.NewCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jar
+}
+
+func TestAuthenticationRequiresPreSharedKey(t *testing.T) {
+	outPath := t.TempDir()
+	sendersPath := filepath.Join(t.TempDir(), "senders")
+	err := os.WriteFile(sendersPath, []byte(network.FormatPublicKey(make([]byte, 32))+" alice\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	senders, err := network.LoadAuthorizedSenders(sendersPath, outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewReceiver(1, network.StopAndWait, nil, senders, network.Limits{}, network.Refuse, false, false, outPath, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err == nil {
+		t.Error("receiver with authorized senders but without a pre-shared key created")
+	}
+}
+
+func TestTotalSizeLimit(t *testing.T) {
+	outPath := t.TempDir()
+	err := os.WriteFile(filepath.Join(outPath, "existing.txt"), []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReceiver(1, network.StopAndWait, nil, nil, network.Limits{MaxTotalSize: 20}, network.Refuse, false, false, outPath, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start(make(chan error, 100))
+	t.Cleanup(func() {
+		r.Stop()
+		_ = r.conn.Close()
+	})
+	conn := newTestSender(t)
+
+	// the info-packet is dropped until the output directory was walked, then the file fits into the limit
+	ack := handshake(t, conn, r, newTestInfo("file.txt", len("hello")))
+	sendData(t, conn, r, ack, 1, "hello")
+	sendFinalize(t, conn, r, ack, 2, "hello")
+	assertFile(t, filepath.Join(outPath, "file.txt"), "hello")
+
+	// the received file is counted without walking the directory again
+	info := newTestInfo("large.txt", len("hello world"))
+	info.SetHeader(packets.NewHeader(0, 43, packets.Info))
+	cookie, ok := exchange(t, conn, r, info).(*packets.CookiePacket)
+	if !ok {
+		t.Fatal("the first info-packet was not answered with a cookie-packet")
+	}
+	info.Cookie = cookie.Cookie
+	reply, ok := exchange(t, conn, r, info).(*packets.ErrorPacket)
+	if !ok || reply.Code() != packets.CodeQuotaExceeded {
+		t.Errorf("file beyond the total size: got %+v; want a %s error", reply, packets.CodeQuotaExceeded)
+	}
+}
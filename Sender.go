@@ -0,0 +1,842 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"github.com/twmb/murmur3"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"satae66.dev/netzeps2022/network"
+	"satae66.dev/netzeps2022/network/packets"
+	"time"
+)
+
+type Sender struct {
+	settings      Settings
+	maxPacketSize int                // maximum size of a whole packet (header + payload)
+	maxVersion    uint8              // highest header version offered in the info handshake
+	resume        bool               // ask the receiver to continue a previously interrupted transmission
+	compression   network.CodecID    // codec offered in the info handshake
+	identity      ed25519.PrivateKey // key the sender authenticates with if the receiver requests it; nil for anonymous senders
+
+	cc network.CongestionController // decides the number of unacknowledged data-packets in flight (windowed protocols only)
+
+	conn            *net.UDPConn
+	transmission    *network.TransmissionOUT // the current transmission
+	transmissionKey uint32                   // key of the current transmission in transmissions
+	transmissions   *network.Registry[uint32, *network.TransmissionOUT]
+}
+
+func NewSender(networkTimeout int, protocol network.Protocol, maxPacketSize int, checksum bool, resume bool, compression network.CodecID, keyring *network.Keyring, identity ed25519.PrivateKey, cc network.CongestionController, lAddr *net.UDPAddr, rAddr *net.UDPAddr) (*Sender, error) {
+	if networkTimeout < 1 {
+		return nil, errors.New("timeout must be at least 1 second")
+	}
+	if identity != nil && !protocol.UsesAcks() {
+		return nil, errors.New("sender authentication requires a protocol with acks")
+	}
+	minPacketSize := packets.MaxHeaderSize + packets.DataPacketSize
+	if keyring != nil {
+		minPacketSize += packets.SealOverhead
+	}
+	if maxPacketSize < minPacketSize {
+		return nil, fmt.Errorf("packet size must be at least %d bytes", minPacketSize)
+	}
+	if maxPacketSize > math.MaxUint16-8 {
+		return nil, fmt.Errorf("packet size must NOT exceed %d bytes", math.MaxUint16-8)
+	}
+	if cc == nil {
+		return nil, errors.New("cc must not be nil")
+	}
+	if rAddr == nil {
+		return nil, errors.New("rAddr must not be nil")
+	}
+
+	conn, err := net.DialUDP("udp", lAddr, rAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the per-packet checksum is only used if offered to the receiver
+	maxVersion := uint8(packets.MaxHeaderVersion)
+	if !checksum {
+		maxVersion = packets.ChecksumHeaderVersion - 1
+	}
+
+	return &Sender{
+		settings: Settings{
+			networkTimeout: time.Duration(networkTimeout) * time.Second,
+			protocol:       protocol,
+			keyring:        keyring,
+		},
+		maxPacketSize: maxPacketSize,
+		maxVersion:    maxVersion,
+		resume:        resume,
+		compression:   compression,
+		identity:      identity,
+		cc:            cc,
+		conn:          conn,
+		transmissions: network.NewRegistry[uint32, *network.TransmissionOUT](),
+	}, nil
+}
+
+func (s *Sender) Close() error {
+	return s.conn.Close()
+}
+
+// StdinName is the file name that makes Send transmit the data read from stdin
+const StdinName = "-"
+
+// Send transmits the file or directory at filePath to the remote address and blocks until the transmission is finished
+func (s *Sender) Send(filePath string) error {
+	if filePath == StdinName {
+		return s.sendStream(os.Stdin, "stdin")
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return s.sendDir(filePath)
+	}
+
+	info := packets.NewInfoPacket(uint64(stat.Size()), s.maxVersion, s.resume, filepath.Base(filePath))
+	return s.transmit(file, info)
+}
+
+// sendStream transmits data of unknown size until r is exhausted; streams cannot be resumed
+func (s *Sender) sendStream(r io.Reader, name string) error {
+	info := packets.NewInfoPacket(0, s.maxVersion, false, name)
+	info.Stream = true
+	return s.transmit(r, info)
+}
+
+// sendDir transmits the manifest of the directory at dirPath followed by one transmission per file
+func (s *Sender) sendDir(dirPath string) error {
+	dirPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return err
+	}
+	manifest, err := network.BuildManifest(dirPath)
+	if err != nil {
+		return err
+	}
+
+	raw := manifest.ToBytes()
+	info := packets.NewInfoPacket(uint64(len(raw)), s.maxVersion, false, filepath.Base(dirPath))
+	info.Manifest = true
+	err = s.transmit(bytes.NewReader(raw), info)
+	if err != nil {
+		return err
+	}
+	batch := s.transmission.Uid // the files refer to the manifest by its uid
+
+	for _, entry := range manifest.Files() {
+		err = s.sendBatchFile(dirPath, entry, batch)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// sendBatchFile transmits a file of the manifest of the directory at dirPath
+func (s *Sender) sendBatchFile(dirPath string, entry network.ManifestEntry, batch uint32) error {
+	file, err := os.Open(filepath.Join(dirPath, filepath.FromSlash(entry.Path)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info := packets.NewInfoPacket(entry.Size, s.maxVersion, s.resume, entry.Path)
+	info.Batch = batch
+	// never send more than announced in the manifest; a modified file fails the integrity check
+	return s.transmit(io.LimitReader(file, int64(entry.Size)), info)
+}
+
+// transmit sends the info-packet, the data read from r and the finalize-packet in a new transmission
+func (s *Sender) transmit(r io.Reader, info packets.InfoPacket) error {
+	t := s.openNewTransmission(r, info.Filesize, info.Stream)
+	if s.settings.keyring != nil {
+		err := s.initKeys()
+		if err != nil {
+			return err
+		}
+	}
+
+	info.Compression = uint8(s.compression)
+	if s.identity != nil {
+		copy(info.PublicKey[:], s.identity.Public().(ed25519.PublicKey))
+	}
+	ack, err := s.sendInfo(&info)
+	if err != nil {
+		return err
+	}
+	// without acks the offered codec is used; otherwise the receiver may decline it
+	compression := s.compression
+	if s.settings.protocol.UsesAcks() {
+		compression = network.CodecID(ack.Compression)
+	}
+	if compression != network.NoCompression && compression != s.compression {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("receiver chose compression %s that was not offered", compression))
+	}
+	t.Codec, err = network.NewCodec(compression)
+	if err != nil {
+		return err
+	}
+	if s.settings.protocol.UsesAcks() {
+		// the ack of the info-packet carries the negotiated header version; sealed packets always use the same
+		if s.settings.keyring == nil && !packets.IsSupportedVersion(ack.Version) {
+			return network.NewTransmissionError(packets.CodeUnsupportedVersion, &packets.UnsupportedVersionError{Version: ack.Version})
+		}
+		// continue with the session-id assigned by the receiver
+		t.Lock()
+		t.Uid = ack.SessionID
+		t.Version = ack.Version
+		t.Unlock()
+
+		// a zero challenge means that the receiver accepts anonymous senders
+		if s.identity != nil && ack.Challenge != [packets.ChallengeSize]byte{} {
+			ack, err = s.authenticate(info, ack)
+			if err != nil {
+				return err
+			}
+		}
+
+		if ack.Offset > 0 {
+			err = s.skipResumed(ack.Offset)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	t.Lock()
+	t.StartTime = time.Now()
+	t.Unlock()
+	if s.settings.protocol.IsWindowed() {
+		err = s.sendDataWindowed()
+	} else {
+		err = s.sendData()
+	}
+	if err != nil {
+		return err
+	}
+	if t.SizeUnknown {
+		t.Lock()
+		t.TotalSize = t.TransmittedSize
+		t.SizeUnknown = false
+		t.Unlock()
+	}
+
+	checksum := [16]byte{}
+	copy(checksum[:], t.Hash.Sum(nil))
+	finalize := packets.NewFinalizePacket(checksum)
+	_, err = s.sendPacket(&finalize)
+	if err != nil {
+		return err
+	}
+
+	// PRINTING
+	_, _ = fmt.Fprintf(measureLog, "%d\n", time.Since(t.StartTime).Milliseconds())
+	_ = measureLog.Flush()
+	return nil
+}
+
+func (s *Sender) openNewTransmission(r io.Reader, totalSize uint64, sizeUnknown bool) *network.TransmissionOUT {
+	// only the current transmission is displayed
+	if s.transmission != nil {
+		s.transmissions.Remove(s.transmissionKey)
+	}
+
+	s.transmission = &network.TransmissionOUT{
+		Transmission: network.Transmission{
+			Uid:         rand.Uint32(), // replaced by the session-id assigned by the receiver
+			Version:     packets.MinHeaderVersion,
+			TotalSize:   totalSize,
+			SizeUnknown: sizeUnknown,
+			Hash:        murmur3.New128(),
+		},
+		File:      bufio.NewReaderSize(r, math.MaxUint16-8),
+		LastAcked: time.Now(),
+	}
+	s.transmissionKey = s.transmission.Uid
+	s.transmissions.Add(s.transmissionKey, s.transmission)
+	return s.transmission
+}
+
+// maxCookieRetries is the number of times the info-packet is sent again with a new cookie of the receiver
+const maxCookieRetries = 3
+
+// cookieRequest is returned while waiting for an ack if the receiver answered the info-packet with a new cookie
+type cookieRequest struct {
+	cookie [packets.CookieSize]byte
+}
+
+func (e *cookieRequest) Error() string {
+	return "receiver requested a cookie"
+}
+
+// sendInfo sends the info-packet and returns its ack; if the receiver answers with a cookie, the info-packet is sent
+// again with it to prove that the sender receives packets at its address
+func (s *Sender) sendInfo(info *packets.InfoPacket) (packets.AckPacket, error) {
+	t := s.transmission
+	for retries := 0; ; retries++ {
+		ack, err := s.sendPacket(info)
+		var request *cookieRequest
+		if !errors.As(err, &request) {
+			return ack, err
+		}
+		if retries == maxCookieRetries {
+			return packets.AckPacket{}, network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("receiver rejected the cookie of transmission %d %d times", t.Uid, retries))
+		}
+
+		t.Cookie = request.cookie
+		info.Cookie = request.cookie
+		if s.settings.keyring != nil {
+			// the changed info-packet must not be sealed with the key and nonce of the previous one
+			err = s.initKeys()
+			if err != nil {
+				return packets.AckPacket{}, err
+			}
+		}
+	}
+}
+
+// authenticate signs the challenge from the ack of the info-packet; returns the ack of the auth-packet,
+// which completes the handshake
+func (s *Sender) authenticate(info packets.InfoPacket, infoAck packets.AckPacket) (packets.AckPacket, error) {
+	t := s.transmission
+	msg := network.AuthMessage(info, infoAck.SessionID, infoAck.Challenge, t.Salt, t.ReceiverSalt)
+	auth := packets.NewAuthPacket(ed25519.Sign(s.identity, msg))
+	return s.sendPacket(&auth)
+}
+
+// initKeys prepares sealing the packets of the current transmission with keys derived from a fresh salt
+func (s *Sender) initKeys() error {
+	t := s.transmission
+	salt, err := network.NewSalt()
+	if err != nil {
+		return err
+	}
+	t.Version = packets.SealedHeaderVersion
+	t.Salt = salt
+
+	if !s.settings.protocol.UsesAcks() {
+		// without acks the receiver contributes no salt
+		keys, err := s.settings.keyring.SessionKeys(salt, nil)
+		if err != nil {
+			return err
+		}
+		t.Keys = &keys
+	}
+	return nil
+}
+
+// skipResumed skips the first offset bytes of the file that the receiver already has; they are only added to the hash
+func (s *Sender) skipResumed(offset uint64) error {
+	t := s.transmission
+	if offset > t.TotalSize {
+		return network.NewTransmissionError(packets.CodeProtocolViolation, fmt.Errorf("receiver resumed transmission %d at offset %d beyond the file size %d", t.Uid, offset, t.TotalSize))
+	}
+
+	_, err := io.CopyN(t.Hash, t.File, int64(offset))
+	if err != nil {
+		return err
+	}
+
+	t.Lock()
+	t.TransmittedSize = offset
+	t.Unlock()
+	return nil
+}
+
+// nextDataPacket reads the next chunk of the file, adds it to the hash and compresses it with the negotiated codec;
+// returns the packet and the uncompressed size of its data or io.EOF once the file is exhausted
+func (s *Sender) nextDataPacket(buf []byte) (packets.Packet, int, error) {
+	t := s.transmission
+	n, err := io.ReadFull(t.File, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if n == 0 {
+		return nil, 0, err
+	}
+
+	_, _ = t.Hash.Write(buf[:n])
+	if t.Codec != nil {
+		compressed, err := t.Codec.Compress(buf[:n])
+		if err != nil {
+			return nil, 0, err
+		}
+		// incompressible data is sent as is to never exceed the packet size
+		if len(compressed) < n {
+			p := packets.NewCompressedDataPacket(compressed)
+			return &p, n, nil
+		}
+	}
+	p := packets.NewDataPacket(buf[:n])
+	return &p, n, nil
+}
+
+// payloadSize returns the maximum size of the payload of a data-packet of the current transmission
+func (s *Sender) payloadSize() int {
+	size := s.maxPacketSize - packets.HeaderSizeOf(s.transmission.Version)
+	if s.settings.keyring != nil {
+		size -= packets.SealOverhead
+	}
+	return size
+}
+
+// sendData sends the file one packet at a time according to the protocol of the sender
+func (s *Sender) sendData() error {
+	t := s.transmission
+	buf := make([]byte, s.payloadSize())
+	for {
+		p, size, err := s.nextDataPacket(buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = s.sendPacket(p)
+		if err != nil {
+			return err
+		}
+		t.Lock()
+		t.TransmittedSize += uint64(size)
+		t.Unlock()
+	}
+}
+
+// dupAckThreshold is the number of duplicate (Go-Back-N) or later (Selective Repeat) acks after which a packet is considered lost
+const dupAckThreshold = 3
+
+// inFlightPacket is a sent but not yet acknowledged data-packet
+type inFlightPacket struct {
+	seqNr         uint32
+	size          int // uncompressed size of the payload
+	raw           []byte
+	sentAt        time.Time // point of time of the last (re)transmission
+	retransmitted bool      // retransmitted packets are not used as rtt samples (Karn's rule)
+	acked         bool
+	laterAcks     int // number of acks for packets sent after this one (Selective Repeat)
+}
+
+// sendDataWindowed sends the file keeping up to cc.Window() unacknowledged packets in flight (Go-Back-N or Selective Repeat)
+func (s *Sender) sendDataWindowed() error {
+	t := s.transmission
+	buf := make([]byte, s.payloadSize())
+	window := make([]*inFlightPacket, 0, network.MaxWindowSize)
+	eof := false
+
+	lastAck := uint32(0)      // last cumulative ack (Go-Back-N)
+	dupAcks := 0              // number of repetitions of lastAck (Go-Back-N)
+	recoverSeqNr := uint32(0) // losses of packets sent before this sequence-number belong to the last loss event
+
+	for {
+		// fill the window
+		for !eof && len(window) < s.cc.Window() {
+			p, size, err := s.nextDataPacket(buf)
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			p.SetHeader(s.newHeader(p.Type()))
+			raw, err := s.encode(p)
+			if err != nil {
+				return err
+			}
+			_, err = s.conn.Write(raw)
+			if err != nil {
+				return err
+			}
+			window = append(window, &inFlightPacket{seqNr: t.SeqNr, size: size, raw: raw, sentAt: time.Now()})
+			t.SeqNr++
+		}
+		if len(window) == 0 {
+			return nil
+		}
+
+		ack, err := s.nextAck(s.nextRetransmission(window))
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			if time.Now().After(t.LastAcked.Add(s.settings.networkTimeout)) {
+				return network.NewTransmissionError(packets.CodeTimeout, fmt.Errorf("transmission %d timed out waiting for ack of packet %d", t.Uid, window[0].seqNr))
+			}
+			err = s.retransmit(window)
+			if err != nil {
+				return err
+			}
+			t.Lock()
+			t.Rtt.Backoff()
+			t.Unlock()
+			s.cc.OnTimeout()
+			recoverSeqNr = t.SeqNr
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		acked := s.markAcked(window, ack.SequenceNr)
+		if acked > 0 {
+			s.cc.OnAck(acked)
+		}
+
+		// loss detection
+		var lost []*inFlightPacket
+		if s.settings.protocol == network.GoBackN {
+			if acked > 0 || ack.SequenceNr != lastAck {
+				lastAck = ack.SequenceNr
+				dupAcks = 0
+			} else {
+				dupAcks++
+				if dupAcks == dupAckThreshold {
+					lost = window // go back n
+				}
+			}
+		} else {
+			lost = s.detectGaps(window, ack.SequenceNr)
+		}
+		if len(lost) > 0 {
+			if lost[0].seqNr >= recoverSeqNr {
+				s.cc.OnLoss()
+				recoverSeqNr = t.SeqNr
+			}
+			err = s.fastRetransmit(lost)
+			if err != nil {
+				return err
+			}
+		}
+
+		// slide the window past all acknowledged packets at its start
+		slide := 0
+		for slide < len(window) && window[slide].acked {
+			slide++
+		}
+		window = window[slide:]
+	}
+}
+
+// nextRetransmission returns the point of time at which the next packet of the window has to be retransmitted
+func (s *Sender) nextRetransmission(window []*inFlightPacket) time.Time {
+	var next time.Time
+	for _, p := range window {
+		if p.acked {
+			continue
+		}
+		if next.IsZero() || p.sentAt.Before(next) {
+			next = p.sentAt
+		}
+	}
+	return next.Add(s.transmission.Rtt.RTO())
+}
+
+// retransmit resends the whole window (Go-Back-N) or only the packets whose ack is overdue (Selective Repeat)
+func (s *Sender) retransmit(window []*inFlightPacket) error {
+	now := time.Now()
+	rto := s.transmission.Rtt.RTO()
+	for _, p := range window {
+		if p.acked {
+			continue
+		}
+		if s.settings.protocol == network.SelectiveRepeat && now.Before(p.sentAt.Add(rto)) {
+			continue
+		}
+
+		err := s.resend(p, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fastRetransmit resends the unacknowledged packets of lost without waiting for the retransmission-timeout
+func (s *Sender) fastRetransmit(lost []*inFlightPacket) error {
+	now := time.Now()
+	for _, p := range lost {
+		if p.acked {
+			continue
+		}
+
+		err := s.resend(p, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sender) resend(p *inFlightPacket, now time.Time) error {
+	_, err := s.conn.Write(p.raw)
+	if err != nil {
+		return err
+	}
+	p.sentAt = now
+	p.retransmitted = true
+	p.laterAcks = 0
+	return nil
+}
+
+// markAcked marks the packets of the window covered by the ack with sequence-number seqNr and returns their number;
+// acks are cumulative for Go-Back-N and individual for Selective Repeat
+func (s *Sender) markAcked(window []*inFlightPacket, seqNr uint32) int {
+	t := s.transmission
+	t.Lock()
+	defer t.Unlock()
+
+	acked := 0
+	for _, p := range window {
+		if p.acked {
+			continue
+		}
+		if p.seqNr == seqNr && !p.retransmitted {
+			t.Rtt.AddSample(time.Since(p.sentAt))
+		}
+		if p.seqNr == seqNr || (s.settings.protocol == network.GoBackN && p.seqNr < seqNr) {
+			p.acked = true
+			acked++
+			t.TransmittedSize += uint64(p.size)
+			t.LastAcked = time.Now()
+			t.Rtt.ResetBackoff()
+		}
+	}
+	return acked
+}
+
+// detectGaps counts the ack of seqNr for all unacknowledged packets sent before it and returns the ones considered lost
+func (s *Sender) detectGaps(window []*inFlightPacket, seqNr uint32) []*inFlightPacket {
+	var lost []*inFlightPacket
+	for _, p := range window {
+		if p.seqNr >= seqNr {
+			break
+		}
+		if p.acked {
+			continue
+		}
+
+		p.laterAcks++
+		if p.laterAcks == dupAckThreshold {
+			lost = append(lost, p)
+		}
+	}
+	return lost
+}
+
+// newHeader creates the header of the next packet of the current transmission
+func (s *Sender) newHeader(packetType packets.PacketType) packets.Header {
+	t := s.transmission
+	header := packets.NewHeader(t.SeqNr, t.Uid, packetType)
+	header.Version = t.Version
+	return header
+}
+
+// encode serializes p and seals it if the sender has a pre-shared key; the header of p must be set
+func (s *Sender) encode(p packets.Packet) ([]byte, error) {
+	if s.settings.keyring == nil {
+		return packets.Encode(p), nil
+	}
+
+	t := s.transmission
+	if p.GetHeader().SequenceNr == 0 {
+		key, err := s.settings.keyring.InfoKey(t.Salt)
+		if err != nil {
+			return nil, err
+		}
+		return packets.Seal(key, p, t.Salt), nil
+	}
+	if t.Keys == nil {
+		return nil, fmt.Errorf("transmission %d: session keys are unknown", t.Uid)
+	}
+	return packets.Seal(t.Keys.Sender, p, nil), nil
+}
+
+// decode parses a packet of the receiver; with a pre-shared key only packets sealed for the current transmission are
+// accepted and the session keys are derived from the first authentic reply to the info-packet
+func (s *Sender) decode(raw []byte) (packets.Packet, error) {
+	if s.settings.keyring == nil {
+		return packets.Decode(raw)
+	}
+
+	t := s.transmission
+	header, salt, err := packets.ParseSealed(raw)
+	if err == packets.ErrNotSealed {
+		// the receiver has no state to seal cookie-packets with
+		packet, err := packets.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := packet.(*packets.CookiePacket); !ok {
+			return nil, packets.ErrNotSealed
+		}
+		return packet, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header.SequenceNr != 0 {
+		if t.Keys == nil {
+			return nil, fmt.Errorf("transmission %d: session keys are unknown", t.Uid)
+		}
+		return packets.Open(t.Keys.Receiver, raw)
+	}
+
+	// replies to the info-packet carry the salt of the receiver
+	keys, err := s.settings.keyring.SessionKeys(t.Salt, salt)
+	if err != nil {
+		return nil, err
+	}
+	packet, err := packets.Open(keys.Receiver, raw)
+	if err != nil {
+		return nil, err
+	}
+	if t.Keys == nil {
+		t.ReceiverSalt = append([]byte(nil), salt...)
+		t.Keys = &keys
+	}
+	return packet, nil
+}
+
+// sendPacket sends p according to the protocol of the sender and returns its ack, if any
+func (s *Sender) sendPacket(p packets.Packet) (packets.AckPacket, error) {
+	if !s.settings.protocol.UsesAcks() {
+		return packets.AckPacket{}, s.sendUnreliable(p)
+	}
+	return s.sendReliable(p)
+}
+
+// sendUnreliable sends p with the next sequence-number exactly once without waiting for an ack (V1)
+func (s *Sender) sendUnreliable(p packets.Packet) error {
+	t := s.transmission
+	p.SetHeader(s.newHeader(p.Type()))
+	raw, err := s.encode(p)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Write(raw)
+	if err != nil {
+		return err
+	}
+
+	t.SeqNr++
+	return nil
+}
+
+// sendReliable sends p with the next sequence-number and retransmits it until the matching ack arrives (Stop&Wait)
+func (s *Sender) sendReliable(p packets.Packet) (packets.AckPacket, error) {
+	t := s.transmission
+	p.SetHeader(s.newHeader(p.Type()))
+	header := p.GetHeader()
+	raw, err := s.encode(p)
+	if err != nil {
+		return packets.AckPacket{}, err
+	}
+
+	retransmitted := false
+	for {
+		sentAt := time.Now()
+		_, err := s.conn.Write(raw)
+		if err != nil {
+			return packets.AckPacket{}, err
+		}
+
+		ack, err := s.awaitAck(header, sentAt.Add(t.Rtt.RTO()))
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			if time.Now().After(t.LastAcked.Add(s.settings.networkTimeout)) {
+				return packets.AckPacket{}, network.NewTransmissionError(packets.CodeTimeout, fmt.Errorf("transmission %d timed out waiting for ack of packet %d", t.Uid, header.SequenceNr))
+			}
+			t.Lock()
+			t.Rtt.Backoff()
+			t.Unlock()
+			retransmitted = true
+			continue // retransmit
+		} else if err != nil {
+			return packets.AckPacket{}, err
+		}
+
+		t.Lock()
+		if !retransmitted {
+			t.Rtt.AddSample(time.Since(sentAt))
+		}
+		t.Rtt.ResetBackoff()
+		t.Unlock()
+		t.LastAcked = time.Now()
+		t.SeqNr++
+		return ack, nil
+	}
+}
+
+// awaitAck blocks until the ack for header is received or the deadline is exceeded
+func (s *Sender) awaitAck(header packets.Header, deadline time.Time) (packets.AckPacket, error) {
+	for {
+		ack, err := s.nextAck(deadline)
+		if err != nil {
+			return packets.AckPacket{}, err
+		}
+		if ack.SequenceNr != header.SequenceNr {
+			continue // ignore stale acks
+		}
+
+		return ack, nil
+	}
+}
+
+// nextAck blocks until an ack of the current transmission is received or the deadline is exceeded;
+// an error-packet of the receiver aborts the transmission
+func (s *Sender) nextAck(deadline time.Time) (packets.AckPacket, error) {
+	err := s.conn.SetReadDeadline(deadline)
+	if err != nil {
+		return packets.AckPacket{}, err
+	}
+
+	rawBytes := make([]byte, math.MaxUint16-8)
+	for {
+		n, err := s.conn.Read(rawBytes)
+		if err != nil {
+			return packets.AckPacket{}, err
+		}
+
+		packet, err := s.decode(rawBytes[:n])
+		if err != nil {
+			continue // ignore malformed and unauthenticated packets
+		}
+		header := packet.GetHeader()
+		if header.StreamUID != s.transmission.Uid {
+			continue // ignore foreign packets
+		}
+
+		switch p := packet.(type) {
+		case *packets.ErrorPacket:
+			return packets.AckPacket{}, network.NewTransmissionError(p.Code(), fmt.Errorf("receiver aborted transmission %d (%s): %s", header.StreamUID, p.Code(), p.Reason()))
+		case *packets.AckPacket:
+			return *p, nil
+		case *packets.CookiePacket:
+			// cookies equal to the sent one answer earlier retransmissions of the info-packet
+			if s.transmission.SeqNr == 0 && p.Cookie != s.transmission.Cookie {
+				return packets.AckPacket{}, &cookieRequest{cookie: p.Cookie}
+			}
+			continue
+		default:
+			continue // ignore unexpected packets
+		}
+	}
+}
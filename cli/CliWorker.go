@@ -6,36 +6,45 @@ import (
 	"math"
 	"satae66.dev/netzeps2022/network"
 	"strings"
+	"sync"
 	"time"
 )
 
 type UIDrawer struct {
-	run bool
+	stop     chan struct{} // closed by Stop to end the drawing loop
+	stopOnce sync.Once
 
 	sleepPeriod int // time between ui refreshes in milliseconds
 
-	anchor *map[uint8]*network.TransmissionIN // anchor back to the transmission map
+	source Source // provides the transmissions to draw
 }
 
-func NewCliWorker(refreshPerSecond int, anchor *map[uint8]*network.TransmissionIN) (*UIDrawer, error) {
+// Source provides the state of the transmissions to draw; implemented by network.Registry
+type Source interface {
+	Snapshot() []network.Status
+	Subscribe() <-chan network.Event
+	Unsubscribe(subscriber <-chan network.Event)
+}
+
+func NewCliWorker(refreshPerSecond int, source Source) (*UIDrawer, error) {
 	if refreshPerSecond < 1 {
 		return nil, errors.New("ui must be refreshed at least once per second")
 	}
 	if refreshPerSecond > 1000 {
 		return nil, errors.New("ui must NOT be refreshed more than 1000 times per second")
 	}
-	if anchor == nil {
-		return nil, errors.New("anchor must NOT be nil")
+	if source == nil {
+		return nil, errors.New("source must NOT be nil")
 	}
 
 	return &UIDrawer{
+		stop:        make(chan struct{}),
 		sleepPeriod: 1000 / refreshPerSecond,
-		anchor:      anchor,
+		source:      source,
 	}, nil
 }
 
 func (w *UIDrawer) Start() {
-	w.run = true
 	/*
 		// switch stdin into 'raw' mode
 		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
@@ -53,37 +62,49 @@ func (w *UIDrawer) Start() {
 	lineCount := 0
 	printBuffer := strings.Builder{}
 
-	for w.run {
+	// redraw immediately if transmissions are added or removed
+	events := w.source.Subscribe()
+	defer w.source.Unsubscribe(events)
+
+	for {
 		printBuffer.Reset()
 		printBuffer.WriteString(strings.Repeat("\r\033[1A\033[K", lineCount))
 		lineCount = 0
 
-		for i := 0; i < 256; i++ {
-			curTransmission := (*w.anchor)[uint8(i)]
-			if curTransmission == nil {
-				continue
-			}
-
+		for _, curTransmission := range w.source.Snapshot() {
 			uid := curTransmission.Uid
-			progress := calcProgress(curTransmission.TransmittedSize, curTransmission.TotalSize)
 			speed := calcSpeed(curTransmission.TransmittedSize, int(math.Floor(time.Since(curTransmission.StartTime).Seconds())))
-			eta := calcEta(curTransmission.TransmittedSize, curTransmission.TotalSize, speed)
+			progress := 0
+			eta := time.Duration(0)
+			if !curTransmission.SizeUnknown {
+				progress = calcProgress(curTransmission.TransmittedSize, curTransmission.TotalSize)
+				eta = calcEta(curTransmission.TransmittedSize, curTransmission.TotalSize, speed)
+			}
+
+			rtt := curTransmission.Rtt
+			rto := curTransmission.Rto
 
-			printBuffer.WriteString(GetInfoLine(uid, progress, speed, eta))
+			printBuffer.WriteString(GetInfoLine(uid, progress, speed, eta, rtt, rto))
 			printBuffer.WriteString(GetSeparatorLine())
 			lineCount += 2
 		}
 
 		fmt.Print(printBuffer.String())
 
-		time.Sleep(time.Duration(w.sleepPeriod) * time.Millisecond)
+		select {
+		case <-w.stop:
+			fmt.Print("\033[2J")
+			return
+		case <-events:
+		case <-time.After(time.Duration(w.sleepPeriod) * time.Millisecond):
+		}
 	}
-
-	fmt.Print("\033[2J")
 }
 
 func (w *UIDrawer) Stop() {
-	w.run = false
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
 }
 
 func calcProgress(totalSent uint64, totalSize uint64) int {
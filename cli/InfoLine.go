@@ -6,11 +6,15 @@ import (
 	"time"
 )
 
-const infoLineFormat = "%s %03d %s %3d%%  [%-10s] %s %s %s %11s %s\n"
+const infoLineFormat = "%s %10d %s %3d%%  [%-10s] %s %s %s %11s %s %7s %s %7s %s\n"
 
-func GetInfoLine(id uint8, progress int, speed uint32, eta time.Duration) string {
+func GetInfoLine(id uint32, progress int, speed uint32, eta time.Duration, rtt time.Duration, rto time.Duration) string {
 	parsedProgress := parseProgress(progress)
-	return fmt.Sprintf(infoLineFormat, vertical, id, vertical, parsedProgress, strings.Repeat("#", parsedProgress/10), vertical, parseSpeed(speed), vertical, parseEta(eta), vertical)
+	parsedRto := "-"
+	if rtt != 0 {
+		parsedRto = parseRtt(rto)
+	}
+	return fmt.Sprintf(infoLineFormat, vertical, id, vertical, parsedProgress, strings.Repeat("#", parsedProgress/10), vertical, parseSpeed(speed), vertical, parseEta(eta), vertical, parseRtt(rtt), vertical, parsedRto, vertical)
 }
 
 func parseProgress(progress int) int {
@@ -43,3 +47,18 @@ func parseSpeed(speed uint32) string {
 func parseEta(eta time.Duration) string {
 	return fmt.Sprintf("%s", eta)
 }
+
+func parseRtt(rtt time.Duration) string {
+	ms := float64(rtt) / float64(time.Millisecond)
+
+	if rtt == 0 {
+		return "-"
+	} else if rtt >= time.Second {
+		return fmt.Sprintf("%.2fs", rtt.Seconds())
+	} else if ms >= 100 {
+		return fmt.Sprintf("%.0fms", ms)
+	} else if ms >= 10 {
+		return fmt.Sprintf("%.1fms", ms)
+	}
+	return fmt.Sprintf("%.2fms", ms)
+}
@@ -14,7 +14,7 @@ func GetSeparatorLine() string {
 	line := strings.Builder{}
 
 	line.WriteString(vertical)
-	line.WriteString(strings.Repeat(horizontal, 5))
+	line.WriteString(strings.Repeat(horizontal, 12))
 	line.WriteString(vertical)
 	line.WriteString(strings.Repeat(horizontal, 20))
 	line.WriteString(vertical)
@@ -22,6 +22,10 @@ func GetSeparatorLine() string {
 	line.WriteString(vertical)
 	line.WriteString(strings.Repeat(horizontal, 13))
 	line.WriteString(vertical)
+	line.WriteString(strings.Repeat(horizontal, 9))
+	line.WriteString(vertical)
+	line.WriteString(strings.Repeat(horizontal, 9))
+	line.WriteString(vertical)
 	line.WriteString("\n")
 
 	return line.String()
@@ -31,7 +35,7 @@ func GetHeadingLine() string {
 	line := strings.Builder{}
 
 	line.WriteString(vertical)
-	line.WriteString(fmt.Sprintf(" %s ", "UID"))
+	line.WriteString(fmt.Sprintf("    %s     ", "UID"))
 	line.WriteString(vertical)
 	line.WriteString(fmt.Sprintf("      %s      ", "PROGRESS"))
 	line.WriteString(vertical)
@@ -39,6 +43,10 @@ func GetHeadingLine() string {
 	line.WriteString(vertical)
 	line.WriteString(fmt.Sprintf("     %s     ", "EST"))
 	line.WriteString(vertical)
+	line.WriteString(fmt.Sprintf("   %s   ", "RTT"))
+	line.WriteString(vertical)
+	line.WriteString(fmt.Sprintf("   %s   ", "RTO"))
+	line.WriteString(vertical)
 	line.WriteString("\n")
 
 	return line.String()
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"crypto/ed25519"
 	"errors"
 	"flag"
 	"fmt"
+	"math/rand"
 	"net"
 	"os"
 	"satae66.dev/netzeps2022/cli"
+	"satae66.dev/netzeps2022/network"
+	"strconv"
+	"time"
 )
 
 /*
@@ -30,13 +35,25 @@ type DefaultCommand struct {
 
 	maxPacketSize     int
 	connectionTimeout int
+	protocol          string
+	pskFile           string
 }
 
-func (cmd *DefaultCommand) SetDefaultFlags(fs *flag.FlagSet) {
+func (cmd *DefaultCommand) SetDefaultFlags(fs *flag.FlagSet, defaultLocalPort int) {
 	fs.StringVar(&cmd.localAddress, "lAddr", "127.0.0.1", "Listen IP-Address [default = 127.0.0.1]")
-	fs.IntVar(&cmd.localPort, "lPort", 6969, "Listen port [default = 6969]")
+	fs.IntVar(&cmd.localPort, "lPort", defaultLocalPort, fmt.Sprintf("Listen port [default = %d]", defaultLocalPort))
 
 	fs.IntVar(&cmd.connectionTimeout, "timeout", 10, "Timeout of the connection in seconds [default = 10]")
+	fs.StringVar(&cmd.protocol, "protocol", "v2", "Transfer protocol: v1 (no control messages; without cookies a receiver creates files for info-packets with spoofed addresses), v2 (Stop&Wait), gbn (Go-Back-N) or sr (Selective Repeat) [default = v2]")
+	fs.StringVar(&cmd.pskFile, "pskFile", "", "File containing a pre-shared key of at least 16 bytes; encrypts and authenticates all packets, the peer needs the same key")
+}
+
+// Keyring loads the pre-shared key; returns nil if none was specified
+func (cmd *DefaultCommand) Keyring() (*network.Keyring, error) {
+	if cmd.pskFile == "" {
+		return nil, nil
+	}
+	return network.LoadKeyring(cmd.pskFile)
 }
 
 /*
@@ -53,6 +70,12 @@ type SendCommand struct {
 	destinationAddress string
 	destinationPort    int
 	filename           string
+	windowSize         int
+	congestionControl  string
+	checksum           bool
+	resume             bool
+	compression        string
+	identity           string
 }
 
 func NewSendCommand() *SendCommand {
@@ -63,12 +86,18 @@ func NewSendCommand() *SendCommand {
 	cmd.fs.StringVar(&cmd.destinationAddress, "rAddr", "localhost", "Remote IP-Address [default = localhost]")
 	cmd.fs.IntVar(&cmd.destinationPort, "rPort", 6969, "Remote port [default = 6969]")
 	cmd.fs.IntVar(&cmd.maxPacketSize, "packetSize", 512, "Maximum size of each packet [default = 512]")
-	cmd.fs.StringVar(&cmd.filename, "filename", "", "The file to send")
+	cmd.fs.StringVar(&cmd.filename, "filename", "", "The file or directory to send; - sends a stream read from stdin")
+	cmd.fs.IntVar(&cmd.windowSize, "window", 16, "Maximum number of unacknowledged packets in flight for windowed protocols [default = 16]")
+	cmd.fs.BoolVar(&cmd.checksum, "crc", false, "Protect every packet with a CRC32C checksum if the receiver supports it [default = false]")
+	cmd.fs.StringVar(&cmd.compression, "compress", "none", "Compress the data-packets if the receiver supports it: none or deflate [default = none]")
+	cmd.fs.BoolVar(&cmd.resume, "resume", false, "Continue a previously interrupted transmission of the file if the receiver kept its progress [default = false]")
+	cmd.fs.StringVar(&cmd.identity, "identity", "", "File with the private key the sender authenticates with, as created by keygen")
+	cmd.fs.StringVar(&cmd.congestionControl, "cc", "reno", "Congestion control for windowed protocols: reno (AIMD) or fixed (constant window) [default = reno]")
 	return cmd
 }
 
 func (cmd *SendCommand) Init(args []string) error {
-	cmd.SetDefaultFlags(cmd.fs)
+	cmd.SetDefaultFlags(cmd.fs, 0)
 
 	err := cmd.fs.Parse(args)
 	if err != nil {
@@ -92,7 +121,17 @@ type ReceiveCommand struct {
 	fs *flag.FlagSet
 
 	DefaultCommand
-	outDir string
+	outDir            string
+	stdout            bool
+	overwritePolicy   string
+	keepPartial       bool
+	subdirs           bool
+	authorizedSenders string
+	maxSessions       int
+	maxFileSize       string
+	maxTotalSize      string
+	rateLimit         float64
+	rateBurst         int
 }
 
 func NewReceiveCommand() *ReceiveCommand {
@@ -101,11 +140,65 @@ func NewReceiveCommand() *ReceiveCommand {
 	}
 
 	cmd.fs.StringVar(&cmd.outDir, "outDir", ".", "The output directory")
+	cmd.fs.BoolVar(&cmd.stdout, "stdout", false, "Write a single incoming transmission to stdout instead of the output directory and exit afterwards [default = false]")
+	cmd.fs.StringVar(&cmd.overwritePolicy, "overwrite", "refuse", "Policy for existing files: refuse, overwrite, rename (numeric suffix) or skip (if identical, otherwise refuse) [default = refuse]")
+	cmd.fs.BoolVar(&cmd.subdirs, "subdirs", false, "Accept file names with directories and create them below the output directory [default = false]")
+	cmd.fs.StringVar(&cmd.authorizedSenders, "authorizedSenders", "", "File with one authorized sender per line: <public key> <directory below outDir> [<quota, e.g. 10G>]; other senders are rejected; requires -pskFile")
+	cmd.fs.BoolVar(&cmd.keepPartial, "keepPartial", false, "Keep the partial file of a failed transmission; required to resume it after a timeout [default = false]")
+	cmd.fs.IntVar(&cmd.maxSessions, "maxSessions", 0, "Maximum number of concurrent transmissions per sender ip-address; 0 for unlimited [default = 0]")
+	cmd.fs.StringVar(&cmd.maxFileSize, "maxFileSize", "0", "Maximum size of a received file, e.g. 512M; 0 for unlimited [default = 0]")
+	cmd.fs.StringVar(&cmd.maxTotalSize, "maxTotalSize", "0", "Maximum size of all files in the output directory, e.g. 10G; 0 for unlimited [default = 0]")
+	cmd.fs.Float64Var(&cmd.rateLimit, "rateLimit", 0, "Maximum number of packets per second per sender ip-address; excess packets are dropped, 0 for unlimited [default = 0]")
+	cmd.fs.IntVar(&cmd.rateBurst, "rateBurst", 0, "Number of packets a sender ip-address may send at once within the rate limit; 0 for one second of packets [default = 0]")
 	return cmd
 }
 
 func (cmd *ReceiveCommand) Init(args []string) error {
-	cmd.SetDefaultFlags(cmd.fs)
+	cmd.SetDefaultFlags(cmd.fs, 6969)
+	return cmd.fs.Parse(args)
+}
+
+// Limits returns the resource limits of the receiver given by the flags
+func (cmd *ReceiveCommand) Limits() (network.Limits, error) {
+	maxFileSize, err := network.ParseSize(cmd.maxFileSize)
+	if err != nil {
+		return network.Limits{}, err
+	}
+	maxTotalSize, err := network.ParseSize(cmd.maxTotalSize)
+	if err != nil {
+		return network.Limits{}, err
+	}
+	return network.Limits{
+		MaxSessionsPerIP: cmd.maxSessions,
+		MaxFileSize:      maxFileSize,
+		MaxTotalSize:     maxTotalSize,
+		PacketRate:       cmd.rateLimit,
+		PacketBurst:      cmd.rateBurst,
+	}, nil
+}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                      KEYGEN-CMD                                                      |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+type KeygenCommand struct {
+	fs *flag.FlagSet
+
+	out string
+}
+
+func NewKeygenCommand() *KeygenCommand {
+	cmd := &KeygenCommand{
+		fs: flag.NewFlagSet("keygen", flagErrorHandling),
+	}
+
+	cmd.fs.StringVar(&cmd.out, "out", "identity", "File to store the private key in; the public key is stored in the same file with the suffix .pub [default = identity]")
+	return cmd
+}
+
+func (cmd *KeygenCommand) Init(args []string) error {
 	return cmd.fs.Parse(args)
 }
 
@@ -119,6 +212,8 @@ var measureLog *bufio.Writer
 var errorLog *bufio.Writer
 
 func init() {
+	rand.Seed(time.Now().UnixNano())
+
 	logFile, err := os.Create("measure_log.txt")
 	if err != nil {
 		panic(err)
@@ -157,7 +252,7 @@ func main() {
 			fmt.Printf("%v", err)
 			os.Exit(-1)
 		}
-		break
+		return
 	case "receive":
 		cmd := NewReceiveCommand()
 		err = cmd.Init(args)
@@ -166,10 +261,27 @@ func main() {
 			os.Exit(-1)
 		}
 		err = startReceiver(cmd)
+		if err != nil {
+			// stdout may carry the received data
+			_, _ = fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(-1)
+		}
+		if cmd.stdout {
+			return
+		}
+	case "keygen":
+		cmd := NewKeygenCommand()
+		err = cmd.Init(args)
+		if err != nil {
+			fmt.Printf("%v", err)
+			os.Exit(-1)
+		}
+		err = generateIdentity(cmd)
 		if err != nil {
 			fmt.Printf("%v", err)
 			os.Exit(-1)
 		}
+		return
 	default:
 		err = fmt.Errorf("undefined command %q", selectedCommand)
 		os.Exit(-1)
@@ -184,6 +296,36 @@ func startReceiver(cmd *ReceiveCommand) error {
 	lPort := cmd.localPort
 	netTimeout := cmd.connectionTimeout
 	outPath := cmd.outDir
+	keepPartial := cmd.keepPartial
+	subdirs := cmd.subdirs
+
+	protocol, err := network.ParseProtocol(cmd.protocol)
+	if err != nil {
+		return err
+	}
+
+	overwritePolicy, err := network.ParseOverwritePolicy(cmd.overwritePolicy)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := cmd.Keyring()
+	if err != nil {
+		return err
+	}
+
+	var authorizedSenders *network.AuthorizedSenders
+	if cmd.authorizedSenders != "" {
+		authorizedSenders, err = network.LoadAuthorizedSenders(cmd.authorizedSenders, outPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	limits, err := cmd.Limits()
+	if err != nil {
+		return err
+	}
 
 	ip := net.ParseIP(lIp)
 	if ip == nil {
@@ -196,17 +338,21 @@ func startReceiver(cmd *ReceiveCommand) error {
 	}
 
 	// Receiver
-	r, err := NewReceiver(netTimeout, outPath, lAddr)
+	r, err := NewReceiver(netTimeout, protocol, keyring, authorizedSenders, limits, overwritePolicy, keepPartial, subdirs, outPath, lAddr)
 	if err != nil {
 		return err
 	}
 
-	// CLI
-	ui, err := cli.NewCliWorker(1, &r.transmissions)
-	if err != nil {
-		return err
+	// CLI; stdout is reserved for the received data in stdout mode
+	if cmd.stdout {
+		r.ReceiveTo(os.Stdout)
+	} else {
+		ui, err := cli.NewCliWorker(1, r.transmissions)
+		if err != nil {
+			return err
+		}
+		go ui.Start()
 	}
-	go ui.Start()
 
 	// Receiver
 	errorChannel := make(chan error, 10)
@@ -218,21 +364,103 @@ func startReceiver(cmd *ReceiveCommand) error {
 		}
 	}()
 
+	if cmd.stdout {
+		// the receiver ends with its single transmission
+		err = <-r.Done()
+		if err == nil {
+			time.Sleep(outputLinger)
+		}
+		r.Stop()
+		return err
+	}
 	return nil
 }
 
 func startSender(cmd *SendCommand) error {
-	/*
-		lIp := cmd.localAddress
-		lPort := cmd.localPort
-		rIp := cmd.destinationAddress
-		rPort := cmd.destinationPort
-		maxPacketSize := cmd.maxPacketSize
-		netTimeout := cmd.connectionTimeout
-		fileName := cmd.filename
-	*/
-
-	//TODO: implement sender
-
-	return fmt.Errorf("%v", "sender not implemented yet")
+	lIp := cmd.localAddress
+	lPort := cmd.localPort
+	rIp := cmd.destinationAddress
+	rPort := cmd.destinationPort
+	maxPacketSize := cmd.maxPacketSize
+	netTimeout := cmd.connectionTimeout
+	fileName := cmd.filename
+	windowSize := cmd.windowSize
+	congestionControl := cmd.congestionControl
+	checksum := cmd.checksum
+	resume := cmd.resume
+
+	protocol, err := network.ParseProtocol(cmd.protocol)
+	if err != nil {
+		return err
+	}
+
+	cc, err := network.NewCongestionController(congestionControl, windowSize)
+	if err != nil {
+		return err
+	}
+
+	compression, err := network.ParseCodec(cmd.compression)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := cmd.Keyring()
+	if err != nil {
+		return err
+	}
+
+	var identity ed25519.PrivateKey
+	if cmd.identity != "" {
+		identity, err = network.LoadIdentity(cmd.identity)
+		if err != nil {
+			return err
+		}
+	}
+
+	ip := net.ParseIP(lIp)
+	if ip == nil {
+		return fmt.Errorf("ip %q could not be parsed", lIp)
+	}
+
+	lAddr := &net.UDPAddr{
+		IP:   ip,
+		Port: lPort,
+	}
+
+	// resolve the remote address in the same address family as the local one
+	udpNetwork := "udp6"
+	if ip.To4() != nil {
+		udpNetwork = "udp4"
+	}
+	rAddr, err := net.ResolveUDPAddr(udpNetwork, net.JoinHostPort(rIp, strconv.Itoa(rPort)))
+	if err != nil {
+		return err
+	}
+
+	// Sender
+	s, err := NewSender(netTimeout, protocol, maxPacketSize, checksum, resume, compression, keyring, identity, cc, lAddr, rAddr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// CLI
+	ui, err := cli.NewCliWorker(1, s.transmissions)
+	if err != nil {
+		return err
+	}
+	go ui.Start()
+	defer ui.Stop()
+
+	return s.Send(fileName)
+}
+
+func generateIdentity(cmd *KeygenCommand) error {
+	publicKey, err := network.GenerateIdentity(cmd.out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("private key: %s\npublic key: %s%s\n%s\n", cmd.out, cmd.out, network.PublicKeySuffix, network.FormatPublicKey(publicKey))
+	return nil
 }
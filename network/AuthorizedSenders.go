@@ -0,0 +1,138 @@
+package network
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AuthorizedSender is an entry of the authorized senders file
+type AuthorizedSender struct {
+	PublicKey ed25519.PublicKey
+	Dir       string // directory the files of the sender are stored in
+	Quota     uint64 // maximum size of all files in Dir in bytes; 0 for unlimited
+}
+
+// AuthorizedSenders maps the public keys of the senders a receiver accepts files from to their directories and quotas
+type AuthorizedSenders struct {
+	senders map[string]*AuthorizedSender
+}
+
+// LoadAuthorizedSenders reads the file at filePath with one sender per line: <public key> <directory> [<quota>].
+// Relative directories are resolved below outPath and created if missing; the quota is a size like 512M or 10G.
+// Empty lines and lines starting with # are ignored.
+func LoadAuthorizedSenders(filePath string, outPath string) (*AuthorizedSenders, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	senders := &AuthorizedSenders{senders: make(map[string]*AuthorizedSender)}
+	scanner := bufio.NewScanner(file)
+	for lineNr := 1; scanner.Scan(); lineNr++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sender, err := parseAuthorizedSender(line, outPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filePath, lineNr, err)
+		}
+		if _, ok := senders.senders[string(sender.PublicKey)]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate key %s", filePath, lineNr, FormatPublicKey(sender.PublicKey))
+		}
+		senders.senders[string(sender.PublicKey)] = sender
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return senders, nil
+}
+
+func parseAuthorizedSender(line string, outPath string) (*AuthorizedSender, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("expected <public key> <directory> [<quota>]")
+	}
+
+	publicKey, err := ParsePublicKey(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	dir := fields[1]
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(outPath, dir)
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	quota := uint64(0)
+	if len(fields) == 3 {
+		quota, err = ParseSize(fields[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &AuthorizedSender{PublicKey: publicKey, Dir: dir, Quota: quota}, nil
+}
+
+// Lookup returns the authorized sender with the given public key
+func (a *AuthorizedSenders) Lookup(publicKey []byte) (*AuthorizedSender, bool) {
+	sender, ok := a.senders[string(publicKey)]
+	return sender, ok
+}
+
+// sizeUnits are the binary units accepted by ParseSize
+var sizeUnits = map[byte]uint64{
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+}
+
+// ParseSize parses a number of bytes with an optional binary unit K, M, G or T
+func ParseSize(s string) (uint64, error) {
+	unit := uint64(1)
+	number := s
+	if s != "" {
+		if u, ok := sizeUnits[strings.ToUpper(s)[len(s)-1]]; ok {
+			unit = u
+			number = s[:len(s)-1]
+		}
+	}
+
+	size, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || size > (1<<64-1)/unit {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return size * unit, nil
+}
+
+// DirUsage returns the total size of the regular files below dir, including partially received ones
+func DirUsage(dir string) (uint64, error) {
+	usage := uint64(0)
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage += uint64(info.Size())
+		return nil
+	})
+	return usage, err
+}
@@ -0,0 +1,39 @@
+package network
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	valid := map[string]uint64{
+		"0":                    0,
+		"512":                  512,
+		"10K":                  10 << 10,
+		"10k":                  10 << 10,
+		"3M":                   3 << 20,
+		"2G":                   2 << 30,
+		"1T":                   1 << 40,
+		"16777215T":            (1<<24 - 1) << 40,
+		"18446744073709551615": 1<<64 - 1,
+	}
+	for s, want := range valid {
+		got, err := ParseSize(s)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"K",
+		"-1",
+		"1.5G",
+		"10X",
+		"16777216T",            // overflows with the unit
+		"18446744073709551616", // overflows without a unit
+		"17179869184G",
+	}
+	for _, s := range invalid {
+		if size, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) = %d; want an error", s, size)
+		}
+	}
+}
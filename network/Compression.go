@@ -0,0 +1,141 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// CodecID identifies a compression codec in the info handshake
+type CodecID uint8
+
+const (
+	NoCompression CodecID = 0x00
+	Deflate       CodecID = 0x01
+)
+
+// Codec compresses the payload of single data-packets; every packet is compressed independently
+type Codec interface {
+	Compress(data []byte) ([]byte, error)
+	// Decompress fails if the decompressed data exceeds limit bytes
+	Decompress(data []byte, limit int) ([]byte, error)
+}
+
+type codecEntry struct {
+	name     string
+	newCodec func() Codec
+}
+
+var codecs = map[CodecID]codecEntry{}
+
+// RegisterCodec makes a codec available for negotiation; codecs register themselves in an init function
+func RegisterCodec(id CodecID, name string, newCodec func() Codec) {
+	if id == NoCompression || newCodec == nil {
+		panic("network: RegisterCodec with invalid id or nil constructor")
+	}
+	if _, ok := codecs[id]; ok {
+		panic(fmt.Sprintf("network: RegisterCodec called twice for codec %#x", byte(id)))
+	}
+	codecs[id] = codecEntry{name: name, newCodec: newCodec}
+}
+
+// NewCodec creates a codec of the given id; NoCompression returns nil
+func NewCodec(id CodecID) (Codec, error) {
+	if id == NoCompression {
+		return nil, nil
+	}
+	entry, ok := codecs[id]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression %s", id)
+	}
+	return entry.newCodec(), nil
+}
+
+func ParseCodec(name string) (CodecID, error) {
+	if name == "none" {
+		return NoCompression, nil
+	}
+	for id, entry := range codecs {
+		if entry.name == name {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("undefined compression %q", name)
+}
+
+func (id CodecID) String() string {
+	if id == NoCompression {
+		return "none"
+	}
+	entry, ok := codecs[id]
+	if !ok {
+		return fmt.Sprintf("CodecID(%d)", uint8(id))
+	}
+	return entry.name
+}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                       DEFLATE                                                        |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+func init() {
+	RegisterCodec(Deflate, "deflate", func() Codec { return NewDeflateCodec() })
+}
+
+// DeflateCodec compresses with DEFLATE (RFC 1951); the writer and reader are reused between packets
+type DeflateCodec struct {
+	buf    bytes.Buffer
+	writer *flate.Writer
+	reader io.ReadCloser
+}
+
+func NewDeflateCodec() *DeflateCodec {
+	return &DeflateCodec{}
+}
+
+func (c *DeflateCodec) Compress(data []byte) ([]byte, error) {
+	c.buf.Reset()
+	if c.writer == nil {
+		writer, err := flate.NewWriter(&c.buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		c.writer = writer
+	} else {
+		c.writer.Reset(&c.buf)
+	}
+
+	_, err := c.writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = c.writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), c.buf.Bytes()...), nil
+}
+
+func (c *DeflateCodec) Decompress(data []byte, limit int) ([]byte, error) {
+	if c.reader == nil {
+		c.reader = flate.NewReader(bytes.NewReader(data))
+	} else {
+		err := c.reader.(flate.Resetter).Reset(bytes.NewReader(data), nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// read one byte more than allowed to detect oversized packets
+	decompressed, err := io.ReadAll(io.LimitReader(c.reader, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > limit {
+		return nil, fmt.Errorf("decompressed packet exceeds %d bytes", limit)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,110 @@
+package network
+
+import (
+	"fmt"
+	"math"
+)
+
+// CongestionController decides how many unacknowledged packets a windowed sender may have in flight
+type CongestionController interface {
+	Window() int       // number of packets that may currently be in flight
+	OnAck(packets int) // called when packets were newly acknowledged
+	OnLoss()           // called once per loss event detected by duplicate or selective acks
+	OnTimeout()        // called when the retransmission-timeout expired
+}
+
+var congestionControllerNames = []string{"reno", "fixed"}
+
+// NewCongestionController creates the congestion controller with the given name; windowSize is the maximum window
+func NewCongestionController(name string, windowSize int) (CongestionController, error) {
+	if windowSize < 1 || windowSize > MaxWindowSize {
+		return nil, fmt.Errorf("window size must be between 1 and %d", MaxWindowSize)
+	}
+
+	switch name {
+	case "reno":
+		return NewRenoController(windowSize), nil
+	case "fixed":
+		return NewFixedController(windowSize), nil
+	default:
+		return nil, fmt.Errorf("undefined congestion control %q; expected one of %v", name, congestionControllerNames)
+	}
+}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                        FIXED                                                         |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+// FixedController always allows the same number of packets in flight; used for reproducible benchmarks
+type FixedController struct {
+	windowSize int
+}
+
+func NewFixedController(windowSize int) *FixedController {
+	return &FixedController{windowSize: windowSize}
+}
+
+func (c *FixedController) Window() int {
+	return c.windowSize
+}
+
+func (c *FixedController) OnAck(_ int) {}
+
+func (c *FixedController) OnLoss() {}
+
+func (c *FixedController) OnTimeout() {}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                         RENO                                                         |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+const (
+	renoInitialWindow = 4 // congestion window at the start of the transmission
+	renoMinThreshold  = 2 // lower bound of the slow start threshold
+)
+
+// RenoController implements AIMD with slow start and congestion avoidance (TCP Reno)
+type RenoController struct {
+	maxWindow int
+
+	cwnd     float64 // congestion window in packets
+	ssthresh float64 // slow start threshold in packets
+}
+
+func NewRenoController(maxWindow int) *RenoController {
+	return &RenoController{
+		maxWindow: maxWindow,
+		cwnd:      math.Min(renoInitialWindow, float64(maxWindow)),
+		ssthresh:  float64(maxWindow),
+	}
+}
+
+func (c *RenoController) Window() int {
+	return int(math.Max(1, math.Floor(c.cwnd)))
+}
+
+func (c *RenoController) OnAck(packets int) {
+	for i := 0; i < packets; i++ {
+		if c.cwnd < c.ssthresh {
+			c.cwnd++ // slow start: exponential growth
+		} else {
+			c.cwnd += 1 / c.cwnd // congestion avoidance: additive increase
+		}
+	}
+	c.cwnd = math.Min(c.cwnd, float64(c.maxWindow))
+}
+
+func (c *RenoController) OnLoss() {
+	// multiplicative decrease (fast recovery)
+	c.ssthresh = math.Max(c.cwnd/2, renoMinThreshold)
+	c.cwnd = c.ssthresh
+}
+
+func (c *RenoController) OnTimeout() {
+	c.ssthresh = math.Max(c.cwnd/2, renoMinThreshold)
+	c.cwnd = 1
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"satae66.dev/netzeps2022/network/packets"
+	"time"
+)
+
+// CookieEpoch is the period after which new cookies are issued; a cookie is accepted in its epoch and the next one
+const CookieEpoch = 30 * time.Second
+
+// CookieJar issues and verifies the cookies of the info handshake without keeping state per sender.
+// A cookie is a MAC over the address and uid of the sender, so only a sender that receives packets at its address can
+// answer with it; the receiver creates no transmission or file before.
+type CookieJar struct {
+	secret []byte
+}
+
+// NewCookieJar creates a cookie jar with a random secret; cookies of other jars are never accepted
+func NewCookieJar() (*CookieJar, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieJar{secret: secret}, nil
+}
+
+// Issue returns the cookie of the current epoch for the sender with the given address and uid
+func (j *CookieJar) Issue(peer *net.UDPAddr, clientUid uint32) [packets.CookieSize]byte {
+	return j.issueAt(peer, clientUid, time.Now())
+}
+
+// Verify reports whether cookie was issued to the sender with the given address and uid in the current or last epoch
+func (j *CookieJar) Verify(peer *net.UDPAddr, clientUid uint32, cookie [packets.CookieSize]byte) bool {
+	return j.verifyAt(peer, clientUid, cookie, time.Now())
+}
+
+func (j *CookieJar) issueAt(peer *net.UDPAddr, clientUid uint32, now time.Time) [packets.CookieSize]byte {
+	return j.cookie(peer, clientUid, epochOf(now))
+}
+
+func (j *CookieJar) verifyAt(peer *net.UDPAddr, clientUid uint32, cookie [packets.CookieSize]byte, now time.Time) bool {
+	epoch := epochOf(now)
+	for _, e := range []uint64{epoch, epoch - 1} {
+		expected := j.cookie(peer, clientUid, e)
+		if hmac.Equal(expected[:], cookie[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *CookieJar) cookie(peer *net.UDPAddr, clientUid uint32, epoch uint64) [packets.CookieSize]byte {
+	fields := make([]byte, 8+2+4)
+	binary.LittleEndian.PutUint64(fields[:8], epoch)
+	binary.LittleEndian.PutUint16(fields[8:10], uint16(peer.Port))
+	binary.LittleEndian.PutUint32(fields[10:14], clientUid)
+
+	mac := hmac.New(sha256.New, j.secret)
+	mac.Write(fields)
+	mac.Write(peer.IP.To16())
+
+	cookie := [packets.CookieSize]byte{}
+	copy(cookie[:], mac.Sum(nil))
+	return cookie
+}
+
+func epochOf(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(CookieEpoch))
+}
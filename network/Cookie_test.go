@@ -0,0 +1,69 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCookieEpochs(t *testing.T) {
+	jar, err := NewCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4000}
+	// the start of an epoch
+	issued := time.Unix(0, int64(1000*CookieEpoch))
+	cookie := jar.issueAt(peer, 7, issued)
+
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{now: issued, want: true},
+		{now: issued.Add(CookieEpoch - 1), want: true},
+		{now: issued.Add(CookieEpoch), want: true},
+		{now: issued.Add(2*CookieEpoch - 1), want: true},
+		{now: issued.Add(2 * CookieEpoch), want: false},
+		{now: issued.Add(-1), want: false},
+	}
+	for _, test := range tests {
+		if got := jar.verifyAt(peer, 7, cookie, test.now); got != test.want {
+			t.Errorf("cookie verified %v after issuing: %v; want %v", test.now.Sub(issued), got, test.want)
+		}
+	}
+}
+
+func TestCookieBinding(t *testing.T) {
+	jar, err := NewCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4000}
+	now := time.Now()
+	cookie := jar.issueAt(peer, 7, now)
+
+	if !jar.verifyAt(peer, 7, cookie, now) {
+		t.Fatal("cookie rejected for the peer it was issued to")
+	}
+	if jar.verifyAt(&net.UDPAddr{IP: peer.IP, Port: 4001}, 7, cookie, now) {
+		t.Error("cookie accepted for another port")
+	}
+	if jar.verifyAt(&net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 4000}, 7, cookie, now) {
+		t.Error("cookie accepted for another ip-address")
+	}
+	if jar.verifyAt(peer, 8, cookie, now) {
+		t.Error("cookie accepted for another uid")
+	}
+	if other.verifyAt(peer, 7, cookie, now) {
+		t.Error("cookie accepted by another jar")
+	}
+	// IPv4 addresses are bound in their 16 byte form, so both forms are the same sender
+	if !jar.verifyAt(&net.UDPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 4000}, 7, cookie, now) {
+		t.Error("cookie rejected for the IPv4-mapped address of the peer")
+	}
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"github.com/twmb/murmur3"
+	"io"
+	"os"
+	"time"
+)
+
+// FileHasher hashes existing files in the background and caches the hashes by path, size and modification time, so
+// repeated requests for the same file read it only once. It is not safe for concurrent use
+type FileHasher struct {
+	files map[string]*FileHash
+}
+
+// FileHash is the hash of an existing file; Sum must not be called before the job is done
+type FileHash struct {
+	*HashJob
+	Size    int64 // size of the file when the hash was started
+	modTime time.Time
+	hash    murmur3.Hash128
+}
+
+func NewFileHasher() *FileHasher {
+	return &FileHasher{
+		files: make(map[string]*FileHash),
+	}
+}
+
+// Hash returns the hash of the regular file at filePath; it is computed in the background unless it is cached
+func (h *FileHasher) Hash(filePath string) (*FileHash, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	cached, ok := h.files[filePath]
+	if ok && cached.Size == stat.Size() && cached.modTime.Equal(stat.ModTime()) && !cached.failed() {
+		_ = file.Close()
+		return cached, nil
+	}
+
+	f := &FileHash{
+		Size:    stat.Size(),
+		modTime: stat.ModTime(),
+		hash:    murmur3.New128(),
+	}
+	f.HashJob = startJob(func() (int64, error) {
+		defer file.Close()
+		return io.Copy(f.hash, file)
+	})
+	h.files[filePath] = f
+	return f, nil
+}
+
+// Sum returns the hash of the file; only valid once the job is done without error
+func (f *FileHash) Sum() []byte {
+	return f.hash.Sum(nil)
+}
+
+// failed reports whether the job is done with an error, so the file has to be hashed again
+func (f *FileHash) failed() bool {
+	if !f.Done() {
+		return false
+	}
+	_, err := f.Result()
+	return err != nil
+}
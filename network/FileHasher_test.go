@@ -0,0 +1,60 @@
+package network
+
+import (
+	"bytes"
+	"github.com/twmb/murmur3"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileHasherCachesUnchangedFiles(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	mustWrite(t, filePath)
+	hasher := NewFileHasher()
+
+	first, err := hasher.Hash(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, first.HashJob)
+	want := murmur3.New128()
+	_, _ = want.Write([]byte("data"))
+	if first.Size != 4 || !bytes.Equal(first.Sum(), want.Sum(nil)) {
+		t.Errorf("hash of %d bytes %x; want 4 bytes %x", first.Size, first.Sum(), want.Sum(nil))
+	}
+
+	second, err := hasher.Hash(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Error("the unchanged file was hashed again")
+	}
+
+	// a modified file is hashed again
+	err = os.WriteFile(filePath, []byte("other data"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chtimes(filePath, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := hasher.Hash(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, third.HashJob)
+	if third == first || third.Size != int64(len("other data")) {
+		t.Error("the modified file was not hashed again")
+	}
+}
+
+func TestFileHasherMissingFile(t *testing.T) {
+	_, err := NewFileHasher().Hash(filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Hash of a missing file: got error %v; want a not-exist error", err)
+	}
+}
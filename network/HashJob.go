@@ -0,0 +1,44 @@
+package network
+
+import (
+	"hash"
+	"io"
+)
+
+// HashJob hashes data in the background, so reading a large file does not block the handling of packets
+type HashJob struct {
+	done chan struct{}
+	size int64
+	err  error
+}
+
+// StartHash writes the data of r to h in a new goroutine; h must not be used until the job is done
+func StartHash(h hash.Hash, r io.Reader) *HashJob {
+	return startJob(func() (int64, error) {
+		return io.Copy(h, r)
+	})
+}
+
+func startJob(fn func() (int64, error)) *HashJob {
+	j := &HashJob{done: make(chan struct{})}
+	go func() {
+		j.size, j.err = fn()
+		close(j.done)
+	}()
+	return j
+}
+
+// Done reports whether the job finished without waiting for it
+func (j *HashJob) Done() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result returns the number of hashed bytes and the error of the job; only valid once it is done
+func (j *HashJob) Result() (int64, error) {
+	return j.size, j.err
+}
@@ -0,0 +1,48 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"github.com/twmb/murmur3"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestStartHash(t *testing.T) {
+	data := bytes.Repeat([]byte("data"), 1024)
+	hash := murmur3.New128()
+	job := StartHash(hash, bytes.NewReader(data))
+	waitFor(t, job)
+
+	size, err := job.Result()
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("Result() = %d, %v; want %d", size, err, len(data))
+	}
+	want := murmur3.New128()
+	_, _ = want.Write(data)
+	if !bytes.Equal(hash.Sum(nil), want.Sum(nil)) {
+		t.Error("hash differs from hashing the data directly")
+	}
+}
+
+func TestStartHashError(t *testing.T) {
+	readErr := errors.New("read failed")
+	job := StartHash(murmur3.New128(), iotest.ErrReader(readErr))
+	waitFor(t, job)
+
+	if _, err := job.Result(); !errors.Is(err, readErr) {
+		t.Errorf("Result() error = %v; want %v", err, readErr)
+	}
+}
+
+func waitFor(t *testing.T, job *HashJob) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !job.Done() {
+		if time.Now().After(deadline) {
+			t.Fatal("hash job did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"satae66.dev/netzeps2022/network/packets"
+	"strings"
+)
+
+// PublicKeySuffix is appended to the path of an identity to get the path of its public key
+const PublicKeySuffix = ".pub"
+
+// authContext separates the signatures of the handshake from other uses of the key
+const authContext = "netzeps2022 auth"
+
+// GenerateIdentity creates a new Ed25519 key pair and stores it in the file at keyPath and its public key file;
+// existing files are never replaced
+func GenerateIdentity(keyPath string) (ed25519.PublicKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeNewFile(keyPath, base64.StdEncoding.EncodeToString(privateKey.Seed())+"\n", 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = writeNewFile(keyPath+PublicKeySuffix, FormatPublicKey(publicKey)+"\n", 0644)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
+
+func writeNewFile(filePath string, content string, perm os.FileMode) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(content)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadIdentity reads the private key of the sender written by GenerateIdentity
+func LoadIdentity(keyPath string) (ed25519.PrivateKey, error) {
+	raw, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a private key", keyPath)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParsePublicKey parses a public key in the format of FormatPublicKey
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%q is not a public key", s)
+	}
+	return raw, nil
+}
+
+// FormatPublicKey encodes a public key as base64
+func FormatPublicKey(publicKey ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// NewChallenge returns a random challenge for an authenticating sender
+func NewChallenge() ([packets.ChallengeSize]byte, error) {
+	challenge := [packets.ChallengeSize]byte{}
+	_, err := rand.Read(challenge[:])
+	return challenge, err
+}
+
+// AuthMessage returns the message an authenticating sender signs in the auth-packet; it binds the signature to the
+// challenge and session-id of the receiver, to the info-packet and to the salts of a sealed transmission
+func AuthMessage(info packets.InfoPacket, sessionID uint32, challenge [packets.ChallengeSize]byte, senderSalt []byte, receiverSalt []byte) []byte {
+	// the salts are nil without a pre-shared key
+	fields := make([]byte, packets.ChallengeSize+4+2*packets.SaltSize)
+	copy(fields[:packets.ChallengeSize], challenge[:])
+	binary.LittleEndian.PutUint32(fields[packets.ChallengeSize:packets.ChallengeSize+4], sessionID)
+	copy(fields[packets.ChallengeSize+4:packets.ChallengeSize+4+packets.SaltSize], senderSalt)
+	copy(fields[packets.ChallengeSize+4+packets.SaltSize:], receiverSalt)
+
+	msg := append([]byte(authContext), fields...)
+	return append(msg, info.ToBytes()...)
+}
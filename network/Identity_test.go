@@ -0,0 +1,49 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"satae66.dev/netzeps2022/network/packets"
+	"testing"
+)
+
+func TestAuthMessageBindsTheHandshake(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := packets.NewInfoPacket(4, packets.MaxHeaderVersion, false, "file.txt")
+	challenge := [packets.ChallengeSize]byte{1, 2, 3}
+	senderSalt := bytes.Repeat([]byte{1}, packets.SaltSize)
+	receiverSalt := bytes.Repeat([]byte{2}, packets.SaltSize)
+	signature := ed25519.Sign(privateKey, AuthMessage(info, 42, challenge, senderSalt, receiverSalt))
+
+	if !ed25519.Verify(publicKey, AuthMessage(info, 42, challenge, senderSalt, receiverSalt), signature) {
+		t.Fatal("signature of the handshake rejected")
+	}
+
+	otherInfo := packets.NewInfoPacket(4, packets.MaxHeaderVersion, false, "other.txt")
+	otherChallenge := [packets.ChallengeSize]byte{3, 2, 1}
+	tests := map[string][]byte{
+		"other challenge":     AuthMessage(info, 42, otherChallenge, senderSalt, receiverSalt),
+		"other session-id":    AuthMessage(info, 43, challenge, senderSalt, receiverSalt),
+		"other info-packet":   AuthMessage(otherInfo, 42, challenge, senderSalt, receiverSalt),
+		"other sender salt":   AuthMessage(info, 42, challenge, receiverSalt, receiverSalt),
+		"other receiver salt": AuthMessage(info, 42, challenge, senderSalt, senderSalt),
+		"swapped salts":       AuthMessage(info, 42, challenge, receiverSalt, senderSalt),
+	}
+	for name, msg := range tests {
+		if ed25519.Verify(publicKey, msg, signature) {
+			t.Errorf("%s: signature accepted", name)
+		}
+	}
+
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ed25519.Verify(otherPublicKey, AuthMessage(info, 42, challenge, senderSalt, receiverSalt), signature) {
+		t.Error("signature accepted with another public key")
+	}
+}
@@ -0,0 +1,111 @@
+package network
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"satae66.dev/netzeps2022/network/packets"
+)
+
+// MinKeySize is the minimum size of a pre-shared key in bytes
+const MinKeySize = 16
+
+// labels of the derived keys; they separate the keys of the info-packet and of both directions of a session
+const (
+	infoKeyLabel     = "netzeps2022 info"
+	senderKeyLabel   = "netzeps2022 sender"
+	receiverKeyLabel = "netzeps2022 receiver"
+)
+
+// Keyring derives the AES-256-GCM keys of sealed transmissions from a pre-shared key.
+// The info-packet is sealed with a key derived from the salt of the sender; all other packets are sealed with session
+// keys derived from the salts of both peers, so every transmission uses fresh keys in each direction.
+type Keyring struct {
+	psk []byte
+}
+
+func NewKeyring(psk []byte) (*Keyring, error) {
+	if len(psk) < MinKeySize {
+		return nil, fmt.Errorf("pre-shared key must have at least %d bytes", MinKeySize)
+	}
+	return &Keyring{psk: append([]byte(nil), psk...)}, nil
+}
+
+// LoadKeyring reads the pre-shared key from the file at keyPath; surrounding whitespace is ignored
+func LoadKeyring(keyPath string) (*Keyring, error) {
+	raw, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(bytes.TrimSpace(raw))
+}
+
+// NewSalt returns a random salt that contributes to the keys of a single transmission
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, packets.SaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// SessionKeys seal the packets of a transmission after the info-packet
+type SessionKeys struct {
+	Sender   cipher.AEAD // seals the packets of the sender
+	Receiver cipher.AEAD // seals the packets of the receiver, including the replies to the info-packet
+}
+
+// InfoKey returns the key of the info-packet of the sender with the given salt
+func (k *Keyring) InfoKey(senderSalt []byte) (cipher.AEAD, error) {
+	return k.newAEAD(senderSalt, infoKeyLabel)
+}
+
+// SessionKeys returns the keys of the transmission with the given salts; the receiver salt is nil without acks
+func (k *Keyring) SessionKeys(senderSalt []byte, receiverSalt []byte) (SessionKeys, error) {
+	salt := make([]byte, 2*packets.SaltSize)
+	copy(salt[:packets.SaltSize], senderSalt)
+	copy(salt[packets.SaltSize:], receiverSalt)
+
+	sender, err := k.newAEAD(salt, senderKeyLabel)
+	if err != nil {
+		return SessionKeys{}, err
+	}
+	receiver, err := k.newAEAD(salt, receiverKeyLabel)
+	if err != nil {
+		return SessionKeys{}, err
+	}
+	return SessionKeys{Sender: sender, Receiver: receiver}, nil
+}
+
+func (k *Keyring) newAEAD(salt []byte, label string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(k.psk, salt, label, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives a key of the given size from secret with HKDF-SHA256 (RFC 5869)
+func deriveKey(secret []byte, salt []byte, label string, size int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	key := make([]byte, 0, size)
+	var block []byte
+	for i := byte(1); len(key) < size; i++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write([]byte(label))
+		expand.Write([]byte{i})
+		block = expand.Sum(nil)
+		key = append(key, block...)
+	}
+	return key[:size]
+}
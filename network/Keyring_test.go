@@ -0,0 +1,120 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"satae66.dev/netzeps2022/network/packets"
+	"testing"
+)
+
+func TestDeriveKeyRFC5869(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		salt   string
+		info   string
+		okm    string
+	}{
+		{
+			name:   "test case 1",
+			secret: "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+			salt:   "000102030405060708090a0b0c",
+			info:   "f0f1f2f3f4f5f6f7f8f9",
+			okm:    "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		},
+		{
+			name:   "test case 3",
+			secret: "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+			okm:    "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+		},
+	}
+	for _, test := range tests {
+		secret, _ := hex.DecodeString(test.secret)
+		salt, _ := hex.DecodeString(test.salt)
+		info, _ := hex.DecodeString(test.info)
+		want, _ := hex.DecodeString(test.okm)
+
+		if got := deriveKey(secret, salt, string(info), len(want)); !bytes.Equal(got, want) {
+			t.Errorf("%s: deriveKey = %x; want %x", test.name, got, want)
+		}
+	}
+}
+
+func newTestKeyring(t *testing.T, psk string) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring([]byte(psk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestSessionKeysSeparateDirections(t *testing.T) {
+	senderSalt := bytes.Repeat([]byte{1}, packets.SaltSize)
+	receiverSalt := bytes.Repeat([]byte{2}, packets.SaltSize)
+	sender, err := newTestKeyring(t, "pre-shared key!!").SessionKeys(senderSalt, receiverSalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := newTestKeyring(t, "pre-shared key!!").SessionKeys(senderSalt, receiverSalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := packets.NewDataPacket([]byte("data"))
+	p.SetHeader(packets.NewHeader(1, 7, packets.Data))
+	raw := packets.Seal(sender.Sender, &p, nil)
+
+	if _, err := packets.Open(receiver.Sender, raw); err != nil {
+		t.Errorf("packet of the sender rejected by the receiver: %v", err)
+	}
+	// a packet reflected to the sender must not be accepted as a reply of the receiver
+	if _, err := packets.Open(sender.Receiver, raw); err == nil {
+		t.Error("packet of the sender accepted with the key of the receiver")
+	}
+
+	other, err := newTestKeyring(t, "pre-shared key!!").SessionKeys(senderSalt, bytes.Repeat([]byte{3}, packets.SaltSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := packets.Open(other.Sender, raw); err == nil {
+		t.Error("packet accepted with the keys of another transmission")
+	}
+	wrongKey, err := newTestKeyring(t, "another key!!!!!").SessionKeys(senderSalt, receiverSalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := packets.Open(wrongKey.Sender, raw); err == nil {
+		t.Error("packet accepted with another pre-shared key")
+	}
+}
+
+func TestInfoKeyDependsOnSalt(t *testing.T) {
+	keyring := newTestKeyring(t, "pre-shared key!!")
+	salt := bytes.Repeat([]byte{1}, packets.SaltSize)
+	key, err := keyring.InfoKey(salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := packets.NewInfoPacket(4, packets.MaxHeaderVersion, false, "file")
+	info.SetHeader(packets.NewHeader(0, 7, packets.Info))
+	raw := packets.Seal(key, &info, salt)
+
+	if _, err := packets.Open(key, raw); err != nil {
+		t.Fatalf("info-packet rejected: %v", err)
+	}
+	wrongKey, err := keyring.InfoKey(bytes.Repeat([]byte{2}, packets.SaltSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := packets.Open(wrongKey, raw); err == nil {
+		t.Error("info-packet accepted with the key of another salt")
+	}
+}
+
+func TestNewKeyringRejectsShortKeys(t *testing.T) {
+	if _, err := NewKeyring(make([]byte, MinKeySize-1)); err == nil {
+		t.Errorf("key of %d bytes accepted", MinKeySize-1)
+	}
+}
@@ -0,0 +1,219 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"satae66.dev/netzeps2022/network/packets"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bound the resources a receiver spends on its senders; zero values disable a limit
+type Limits struct {
+	MaxSessionsPerIP int     // number of concurrent transmissions per ip-address of a sender
+	MaxFileSize      uint64  // size of a single received file or stream in bytes
+	MaxTotalSize     uint64  // total size of the files in the output directory in bytes, including partial files
+	PacketRate       float64 // number of packets per second accepted per ip-address of a sender
+	PacketBurst      int     // number of packets accepted at once per ip-address; defaults to one second of PacketRate
+}
+
+// Limit identifies a limit of the receiver
+type Limit uint8
+
+const (
+	PacketRateLimit  Limit = iota // Limits.PacketRate
+	SessionLimit                  // Limits.MaxSessionsPerIP
+	FileSizeLimit                 // Limits.MaxFileSize
+	TotalSizeLimit                // Limits.MaxTotalSize
+	SenderQuotaLimit              // AuthorizedSender.Quota
+	limitCount
+)
+
+var limitNames = [limitCount]string{
+	PacketRateLimit:  "packet rate",
+	SessionLimit:     "sessions per ip",
+	FileSizeLimit:    "file size",
+	TotalSizeLimit:   "total size",
+	SenderQuotaLimit: "sender quota",
+}
+
+var limitCodes = [limitCount]packets.ErrorCode{
+	PacketRateLimit:  packets.CodeRejected,
+	SessionLimit:     packets.CodeTooManySessions,
+	FileSizeLimit:    packets.CodeQuotaExceeded,
+	TotalSizeLimit:   packets.CodeQuotaExceeded,
+	SenderQuotaLimit: packets.CodeQuotaExceeded,
+}
+
+func (l Limit) String() string {
+	if l >= limitCount {
+		return fmt.Sprintf("Limit(%d)", uint8(l))
+	}
+	return limitNames[l]
+}
+
+// LimitError reports the violation of a limit of the receiver
+type LimitError struct {
+	Limit Limit
+	Err   error
+}
+
+// NewLimitError returns a TransmissionError with the ErrorCode of limit that wraps a LimitError
+func NewLimitError(limit Limit, err error) *TransmissionError {
+	code := packets.CodeRejected
+	if limit < limitCount {
+		code = limitCodes[limit]
+	}
+	return NewTransmissionError(code, &LimitError{Limit: limit, Err: err})
+}
+
+func (e *LimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                        STATS                                                         |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+// LimitStats counts the violations of each limit; safe for concurrent use
+type LimitStats struct {
+	violations [limitCount]uint64
+}
+
+func (s *LimitStats) Count(limit Limit) {
+	if limit < limitCount {
+		atomic.AddUint64(&s.violations[limit], 1)
+	}
+}
+
+// Violations returns the number of violations of limit
+func (s *LimitStats) Violations(limit Limit) uint64 {
+	if limit >= limitCount {
+		return 0
+	}
+	return atomic.LoadUint64(&s.violations[limit])
+}
+
+// Total returns the number of violations of all limits
+func (s *LimitStats) Total() uint64 {
+	total := uint64(0)
+	for limit := Limit(0); limit < limitCount; limit++ {
+		total += s.Violations(limit)
+	}
+	return total
+}
+
+func (s *LimitStats) String() string {
+	counts := make([]string, 0, limitCount)
+	for limit := Limit(0); limit < limitCount; limit++ {
+		counts = append(counts, fmt.Sprintf("%s: %d", limit, s.Violations(limit)))
+	}
+	return strings.Join(counts, ", ")
+}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                     RATE-LIMITER                                                     |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+// TokenBucket allows rate events per second on average and up to burst events at once
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time // point of time at which the tokens were refilled the last time
+}
+
+func NewTokenBucket(rate float64, burst int, now time.Time) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// Allow takes a token from the bucket if there is one
+func (b *TokenBucket) Allow(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Full reports whether the bucket was refilled completely, i.e. it is indistinguishable from a new one
+func (b *TokenBucket) Full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// pruneInterval is the minimal time between two removals of unused buckets
+const pruneInterval = 10 * time.Second
+
+// RateLimiter limits the packets per second of each ip-address with a TokenBucket; not safe for concurrent use
+type RateLimiter struct {
+	rate      float64
+	burst     int
+	buckets   map[string]*TokenBucket
+	lastPrune time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = int(rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return &RateLimiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*TokenBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// Allow reports whether a packet of ip is within the limit; the ports are ignored as they are chosen by the sender
+func (l *RateLimiter) Allow(ip net.IP, now time.Time) bool {
+	if now.Sub(l.lastPrune) >= pruneInterval {
+		l.prune(now)
+	}
+
+	key := string(ip.To16())
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(l.rate, l.burst, now)
+		l.buckets[key] = bucket
+	}
+	return bucket.Allow(now)
+}
+
+// prune removes the full buckets to bound the memory used for past senders
+func (l *RateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.Full(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
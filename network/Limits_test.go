@@ -0,0 +1,82 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := NewTokenBucket(2, 3, now)
+
+	for i := 0; i < 3; i++ {
+		if !b.Allow(now) {
+			t.Fatalf("event %d of the burst rejected", i)
+		}
+	}
+	if b.Allow(now) {
+		t.Error("event beyond the burst allowed")
+	}
+
+	// two tokens per second are refilled
+	now = now.Add(500 * time.Millisecond)
+	if !b.Allow(now) {
+		t.Error("event after refilling a token rejected")
+	}
+	if b.Allow(now) {
+		t.Error("event beyond the refilled token allowed")
+	}
+
+	// the bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	if !b.Full(now) {
+		t.Error("bucket not full after an hour")
+	}
+	for i := 0; i < 3; i++ {
+		b.Allow(now)
+	}
+	if b.Allow(now) {
+		t.Error("more events than the burst allowed after an hour")
+	}
+
+	// time going backwards does not add tokens
+	if b.Allow(now.Add(-time.Hour)) {
+		t.Error("event allowed after the clock went backwards")
+	}
+}
+
+func TestRateLimiterSeparatesIPs(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(1, 1)
+	if !l.Allow(net.IPv4(127, 0, 0, 1), now) || l.Allow(net.IPv4(127, 0, 0, 1), now) {
+		t.Error("limit of the first ip not applied")
+	}
+	if !l.Allow(net.IPv4(127, 0, 0, 2), now) {
+		t.Error("packet of another ip rejected")
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(1, 2)
+	l.lastPrune = now
+	l.Allow(net.IPv4(127, 0, 0, 1), now)
+	l.Allow(net.IPv4(127, 0, 0, 2), now)
+	l.Allow(net.IPv4(127, 0, 0, 2), now)
+
+	// the bucket of the first ip is full again after a second, the second one needs two seconds
+	l.prune(now.Add(time.Second))
+	if _, ok := l.buckets[string(net.IPv4(127, 0, 0, 1).To16())]; ok {
+		t.Error("full bucket not removed")
+	}
+	if _, ok := l.buckets[string(net.IPv4(127, 0, 0, 2).To16())]; !ok {
+		t.Error("bucket that is not full removed")
+	}
+
+	// Allow prunes once the interval passed
+	l.Allow(net.IPv4(127, 0, 0, 3), now.Add(pruneInterval+time.Second))
+	if len(l.buckets) != 1 {
+		t.Errorf("%d buckets after pruning; want 1", len(l.buckets))
+	}
+}
@@ -0,0 +1,232 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/twmb/murmur3"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// MaxManifestSize is the maximum size of a serialized manifest; the receiver keeps it in memory
+const MaxManifestSize = 16 * 1024 * 1024
+
+// manifestEntrySize is the size of a serialized ManifestEntry without its path
+const manifestEntrySize = 2 + 8 + 4 + 8 + 16
+
+// ManifestEntry describes a file or directory of a transmitted directory
+type ManifestEntry struct {
+	Path    string      // path relative to the transmitted directory; components are separated by '/'
+	Size    uint64      // size of the file; 0 for directories
+	Mode    os.FileMode // type and permission bits
+	ModTime time.Time   // point of time of the last modification
+	Hash    [16]byte    // hash of the file; zero for directories
+}
+
+// Manifest lists the contents of a directory; it is sent before the files to let the receiver verify the whole batch
+type Manifest struct {
+	Entries []ManifestEntry // directories precede their contents
+}
+
+// BuildManifest walks the directory at root and hashes all regular files; other files are skipped
+func BuildManifest(root string) (Manifest, error) {
+	manifest := Manifest{}
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == root || (!d.IsDir() && !d.Type().IsRegular()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		if len(relPath) > math.MaxUint16 {
+			return fmt.Errorf("path %q is too long", relPath)
+		}
+
+		entry := ManifestEntry{
+			Path:    filepath.ToSlash(relPath),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+		if !d.IsDir() {
+			entry.Size = uint64(info.Size())
+			entry.Hash, err = hashFile(filePath)
+			if err != nil {
+				return err
+			}
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	return manifest, err
+}
+
+func hashFile(filePath string) ([16]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return [16]byte{}, err
+	}
+	defer file.Close()
+
+	hash := murmur3.New128()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return [16]byte{}, err
+	}
+
+	sum := [16]byte{}
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
+
+// Files returns the entries of regular files in the order they are transmitted
+func (m Manifest) Files() []ManifestEntry {
+	files := make([]ManifestEntry, 0, len(m.Entries))
+	for _, entry := range m.Entries {
+		if !entry.Mode.IsDir() {
+			files = append(files, entry)
+		}
+	}
+	return files
+}
+
+func ParseManifest(raw []byte) (Manifest, error) {
+	if len(raw) < 4 {
+		return Manifest{}, errors.New("not enough data")
+	}
+	count := binary.LittleEndian.Uint32(raw[:4])
+	raw = raw[4:]
+	if uint64(count)*manifestEntrySize > uint64(len(raw)) {
+		return Manifest{}, fmt.Errorf("manifest with %d entries exceeds its size", count)
+	}
+
+	manifest := Manifest{Entries: make([]ManifestEntry, 0, count)}
+	paths := make(map[string]bool, count)
+	for i := uint32(0); i < count; i++ {
+		if len(raw) < manifestEntrySize {
+			return Manifest{}, errors.New("not enough data")
+		}
+		pathLen := int(binary.LittleEndian.Uint16(raw[:2]))
+		if len(raw) < manifestEntrySize+pathLen {
+			return Manifest{}, errors.New("not enough data")
+		}
+		raw = raw[2:]
+
+		entry := ManifestEntry{
+			Path:    string(raw[:pathLen]),
+			Size:    binary.LittleEndian.Uint64(raw[pathLen : pathLen+8]),
+			Mode:    os.FileMode(binary.LittleEndian.Uint32(raw[pathLen+8 : pathLen+12])),
+			ModTime: time.Unix(0, int64(binary.LittleEndian.Uint64(raw[pathLen+12:pathLen+20]))),
+		}
+		copy(entry.Hash[:], raw[pathLen+20:pathLen+36])
+		raw = raw[pathLen+36:]
+
+		if paths[entry.Path] {
+			return Manifest{}, fmt.Errorf("duplicate manifest entry %q", entry.Path)
+		}
+		paths[entry.Path] = true
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	if len(raw) != 0 {
+		return Manifest{}, errors.New("trailing data after the manifest entries")
+	}
+	return manifest, nil
+}
+
+func (m Manifest) ToBytes() []byte {
+	raw := make([]byte, 4)
+	binary.LittleEndian.PutUint32(raw, uint32(len(m.Entries)))
+	for _, entry := range m.Entries {
+		buf := make([]byte, manifestEntrySize+len(entry.Path))
+		binary.LittleEndian.PutUint16(buf[:2], uint16(len(entry.Path)))
+		n := 2 + copy(buf[2:], entry.Path)
+		binary.LittleEndian.PutUint64(buf[n:n+8], entry.Size)
+		binary.LittleEndian.PutUint32(buf[n+8:n+12], uint32(entry.Mode))
+		binary.LittleEndian.PutUint64(buf[n+12:n+20], uint64(entry.ModTime.UnixNano()))
+		copy(buf[n+20:n+36], entry.Hash[:])
+		raw = append(raw, buf...)
+	}
+	return raw
+}
+
+// SortedDirs returns the directory entries with nested directories first, e.g. to restore their modification times
+func (m Manifest) SortedDirs() []ManifestEntry {
+	dirs := make([]ManifestEntry, 0)
+	for _, entry := range m.Entries {
+		if entry.Mode.IsDir() {
+			dirs = append(dirs, entry)
+		}
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return len(dirs[i].Path) > len(dirs[j].Path)
+	})
+	return dirs
+}
+
+/*
+/----------------------------------------------------------------------------------------------------------------------\
+|                                                        BATCH                                                         |
+\----------------------------------------------------------------------------------------------------------------------/
+*/
+
+// Batch tracks the files of a received manifest on the receiver
+type Batch struct {
+	Key      SessionKey // key of the manifest transmission
+	Manifest Manifest
+	Dir      string            // path of the received directory
+	Sender   *AuthorizedSender // sender of the manifest; nil for anonymous senders
+
+	LastUpdated time.Time
+
+	files map[string]ManifestEntry // files that were not received yet by path
+}
+
+func NewBatch(key SessionKey, manifest Manifest, dir string) *Batch {
+	files := make(map[string]ManifestEntry)
+	for _, entry := range manifest.Files() {
+		files[entry.Path] = entry
+	}
+
+	return &Batch{
+		Key:         key,
+		Manifest:    manifest,
+		Dir:         dir,
+		LastUpdated: time.Now(),
+		files:       files,
+	}
+}
+
+// Entry returns the manifest entry of a file that was not received yet
+func (b *Batch) Entry(filePath string) (ManifestEntry, bool) {
+	entry, ok := b.files[filePath]
+	return entry, ok
+}
+
+// MarkReceived removes the file from the outstanding files of the batch
+func (b *Batch) MarkReceived(filePath string) {
+	delete(b.files, filePath)
+}
+
+// Missing returns the number of files that were not received yet
+func (b *Batch) Missing() int {
+	return len(b.files)
+}
+
+// Complete reports whether all files of the manifest were received and verified
+func (b *Batch) Complete() bool {
+	return len(b.files) == 0
+}
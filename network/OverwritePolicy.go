@@ -0,0 +1,37 @@
+package network
+
+import "fmt"
+
+// OverwritePolicy decides what the receiver does if a file already exists at the path of a received file
+type OverwritePolicy uint8
+
+const (
+	Refuse        OverwritePolicy = iota // abort the transmission and report the existing file to the sender
+	Overwrite                            // replace the existing file
+	Rename                               // store the received file under the name with the lowest free numeric suffix
+	SkipIdentical                        // keep the existing file if its hash matches the sent file, otherwise refuse
+)
+
+var overwritePolicyNames = map[OverwritePolicy]string{
+	Refuse:        "refuse",
+	Overwrite:     "overwrite",
+	Rename:        "rename",
+	SkipIdentical: "skip",
+}
+
+func ParseOverwritePolicy(name string) (OverwritePolicy, error) {
+	for policy, policyName := range overwritePolicyNames {
+		if policyName == name {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("undefined overwrite policy %q", name)
+}
+
+func (p OverwritePolicy) String() string {
+	name, ok := overwritePolicyNames[p]
+	if !ok {
+		return fmt.Sprintf("OverwritePolicy(%d)", uint8(p))
+	}
+	return name
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+)
+
+// PartSuffix is appended to the hidden name of a file while it is received
+const PartSuffix = ".part"
+
+// ProgressSuffix is appended to the path of a partially received file to get the path of its progress-file
+const ProgressSuffix = ".progress"
+
+// progressTmpSuffix is appended to the path of a partially received file while its progress-file is replaced
+const progressTmpSuffix = ProgressSuffix + ".tmp"
+
+// progressSize is the size of a serialized Progress
+const progressSize = 8 + 8
+
+// Progress is the persisted state of a partially received file that allows to resume its transmission
+type Progress struct {
+	Offset    uint64 // number of bytes at the start of the file that were completely written
+	TotalSize uint64 // size of the whole file as announced by the sender
+}
+
+// LoadProgress reads the progress of the partially received file at filePath
+func LoadProgress(filePath string) (Progress, error) {
+	raw, err := os.ReadFile(filePath + ProgressSuffix)
+	if err != nil {
+		return Progress{}, err
+	}
+	if len(raw) != progressSize {
+		return Progress{}, errors.New("malformed progress-file")
+	}
+
+	return Progress{
+		Offset:    binary.LittleEndian.Uint64(raw[:8]),
+		TotalSize: binary.LittleEndian.Uint64(raw[8:16]),
+	}, nil
+}
+
+// SaveProgress persists the progress of the partially received file at filePath;
+// the data up to the offset must already be written to the file
+func SaveProgress(filePath string, p Progress) error {
+	raw := make([]byte, progressSize)
+	binary.LittleEndian.PutUint64(raw[:8], p.Offset)
+	binary.LittleEndian.PutUint64(raw[8:16], p.TotalSize)
+
+	// replace the progress-file atomically to never leave a truncated one behind
+	tmpPath := filePath + progressTmpSuffix
+	err := os.WriteFile(tmpPath, raw, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath+ProgressSuffix)
+}
+
+// RemoveProgress deletes the progress-file of filePath if there is one
+func RemoveProgress(filePath string) error {
+	err := os.Remove(filePath + ProgressSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package network
+
+import "fmt"
+
+// Protocol represents the transfer protocol used by sender and receiver
+type Protocol uint8
+
+const (
+	NoControl       Protocol = iota // V1: no control messages, integrity is only checked via the hash
+	StopAndWait                     // V2: every packet is acknowledged before the next one is sent
+	GoBackN                         // sliding window of unacknowledged packets, in-order delivery and cumulative acks
+	SelectiveRepeat                 // sliding window with out-of-order buffering, individual acks and selective retransmission
+)
+
+// MaxWindowSize is the maximum number of unacknowledged packets in flight; the receiver buffers at most that many
+const MaxWindowSize = 1024
+
+var protocolNames = map[Protocol]string{
+	NoControl:       "v1",
+	StopAndWait:     "v2",
+	GoBackN:         "gbn",
+	SelectiveRepeat: "sr",
+}
+
+func ParseProtocol(name string) (Protocol, error) {
+	for protocol, protocolName := range protocolNames {
+		if protocolName == name {
+			return protocol, nil
+		}
+	}
+	return 0, fmt.Errorf("undefined protocol %q", name)
+}
+
+func (p Protocol) String() string {
+	name, ok := protocolNames[p]
+	if !ok {
+		return fmt.Sprintf("Protocol(%d)", uint8(p))
+	}
+	return name
+}
+
+// UsesAcks reports whether the receiver acknowledges packets of the protocol
+func (p Protocol) UsesAcks() bool {
+	return p != NoControl
+}
+
+// IsWindowed reports whether the sender may have more than one unacknowledged packet in flight
+func (p Protocol) IsWindowed() bool {
+	return p == GoBackN || p == SelectiveRepeat
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"sort"
+	"sync"
+)
+
+// Tracked is implemented by transmissions that can be stored in a Registry
+type Tracked interface {
+	Status() Status
+}
+
+type EventKind uint8
+
+const (
+	Added   EventKind = iota // a transmission was added to the registry
+	Removed                  // a transmission was removed from the registry
+)
+
+// Event notifies subscribers of a Registry about added and removed transmissions
+type Event struct {
+	Kind EventKind
+}
+
+// subscriberBufferSize is the number of events buffered per subscriber; further events are dropped until it catches up
+const subscriberBufferSize = 16
+
+// Registry is a concurrency-safe map of transmissions shared between the network code and the ui.
+// It only guards the map itself; the fields of a transmission are guarded by the lock of the transmission.
+type Registry[K comparable, T Tracked] struct {
+	mutex       sync.RWMutex
+	entries     map[K]T
+	subscribers []chan Event
+}
+
+func NewRegistry[K comparable, T Tracked]() *Registry[K, T] {
+	return &Registry[K, T]{
+		entries: make(map[K]T),
+	}
+}
+
+// Get returns the transmission stored under key
+func (r *Registry[K, T]) Get(key K) (T, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	t, ok := r.entries[key]
+	return t, ok
+}
+
+// Add stores t under key, replacing any previous transmission, and notifies the subscribers
+func (r *Registry[K, T]) Add(key K, t T) {
+	r.mutex.Lock()
+	r.entries[key] = t
+	r.mutex.Unlock()
+
+	r.publish(Event{Kind: Added})
+}
+
+// Remove deletes the transmission stored under key and notifies the subscribers
+func (r *Registry[K, T]) Remove(key K) {
+	r.mutex.Lock()
+	_, ok := r.entries[key]
+	delete(r.entries, key)
+	r.mutex.Unlock()
+
+	if ok {
+		r.publish(Event{Kind: Removed})
+	}
+}
+
+// Len returns the number of stored transmissions
+func (r *Registry[K, T]) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.entries)
+}
+
+// Range calls fn for every transmission until fn returns false.
+// It iterates over a copy of the registry, so fn may add or remove transmissions.
+func (r *Registry[K, T]) Range(fn func(key K, t T) bool) {
+	r.mutex.RLock()
+	keys := make([]K, 0, len(r.entries))
+	values := make([]T, 0, len(r.entries))
+	for key, t := range r.entries {
+		keys = append(keys, key)
+		values = append(values, t)
+	}
+	r.mutex.RUnlock()
+
+	for i := range keys {
+		if !fn(keys[i], values[i]) {
+			return
+		}
+	}
+}
+
+// Snapshot returns the status of all transmissions ordered by uid
+func (r *Registry[K, T]) Snapshot() []Status {
+	statuses := make([]Status, 0, r.Len())
+	r.Range(func(_ K, t T) bool {
+		statuses = append(statuses, t.Status())
+		return true
+	})
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Uid < statuses[j].Uid
+	})
+	return statuses
+}
+
+// Subscribe returns a channel on which all following events are delivered
+func (r *Registry[K, T]) Subscribe() <-chan Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	subscriber := make(chan Event, subscriberBufferSize)
+	r.subscribers = append(r.subscribers, subscriber)
+	return subscriber
+}
+
+// Unsubscribe stops the delivery of events to subscriber and closes it
+func (r *Registry[K, T]) Unsubscribe(subscriber <-chan Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, s := range r.subscribers {
+		if s == subscriber {
+			r.subscribers = append(r.subscribers[:i], r.subscribers[i+1:]...)
+			close(s)
+			return
+		}
+	}
+}
+
+// publish delivers e to all subscribers without blocking the caller
+func (r *Registry[K, T]) publish(e Event) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, subscriber := range r.subscribers {
+		select {
+		case subscriber <- e:
+		default: // subscriber is busy, drop the event
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package network
+
+import "time"
+
+const (
+	InitialRto = 200 * time.Millisecond // retransmission-timeout before the first rtt sample
+	MinRto     = 5 * time.Millisecond
+	MaxRto     = 5 * time.Second
+)
+
+// RttEstimator estimates the round-trip-time and derives the retransmission-timeout from it (RFC 6298)
+type RttEstimator struct {
+	srtt    time.Duration // smoothed round-trip-time
+	rttvar  time.Duration // round-trip-time variation
+	rto     time.Duration // retransmission-timeout derived from the samples
+	backoff uint          // number of consecutive timeouts, every timeout doubles the retransmission-timeout
+}
+
+// AddSample updates the estimation with a measured round-trip-time.
+// Following Karn's rule, samples must only be taken from packets that were NOT retransmitted.
+func (e *RttEstimator) AddSample(rtt time.Duration) {
+	if e.srtt == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		e.rttvar = (3*e.rttvar + delta) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.rto = clampRto(e.srtt + 4*e.rttvar)
+	e.backoff = 0
+}
+
+// Backoff doubles the retransmission-timeout after a timeout occurred
+func (e *RttEstimator) Backoff() {
+	if e.RTO() < MaxRto {
+		e.backoff++
+	}
+}
+
+// ResetBackoff drops the backoff once an ack acknowledged new data, even if no sample could be taken from it
+func (e *RttEstimator) ResetBackoff() {
+	e.backoff = 0
+}
+
+// RTT returns the smoothed round-trip-time or 0 if no sample was taken yet
+func (e *RttEstimator) RTT() time.Duration {
+	return e.srtt
+}
+
+// RTO returns the current retransmission-timeout including backoff
+func (e *RttEstimator) RTO() time.Duration {
+	rto := e.rto
+	if rto == 0 {
+		rto = InitialRto
+	}
+	return clampRto(rto << e.backoff)
+}
+
+func clampRto(rto time.Duration) time.Duration {
+	if rto < MinRto {
+		return MinRto
+	}
+	if rto > MaxRto {
+		return MaxRto
+	}
+	return rto
+}
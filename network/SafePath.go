@@ -0,0 +1,146 @@
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"satae66.dev/netzeps2022/network/packets"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the maximum length in bytes of a single component of a received file name
+const MaxNameLength = 255
+
+// reservedNames are device names on Windows; they refer to devices regardless of directory and extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SplitName validates a file name received from a sender and returns its components, which are separated by '/'.
+// Absolute names, parent traversal, device names, control and format characters and the names of the internal files
+// of the receiver are rejected; names with more than one component are only accepted if subdirs is set.
+func SplitName(name string, subdirs bool) ([]string, error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty file name")
+	}
+	if !utf8.ValidString(name) {
+		return nil, fmt.Errorf("file name %q is not valid UTF-8", name)
+	}
+	for _, c := range name {
+		if unicode.IsControl(c) {
+			return nil, fmt.Errorf("file name %q contains control characters", name)
+		}
+		if unicode.Is(unicode.Cf, c) {
+			// e.g. U+202E reverses the displayed name
+			return nil, fmt.Errorf("file name %q contains format characters", name)
+		}
+	}
+	if strings.ContainsAny(name, `\:`) {
+		return nil, fmt.Errorf("file name %q contains reserved characters", name)
+	}
+	if strings.HasPrefix(name, "/") {
+		return nil, fmt.Errorf("file name %q is absolute", name)
+	}
+
+	components := strings.Split(name, "/")
+	if len(components) > 1 && !subdirs {
+		return nil, fmt.Errorf("file name %q contains directories", name)
+	}
+	for _, component := range components {
+		err := checkComponent(component)
+		if err != nil {
+			return nil, fmt.Errorf("file name %q: %w", name, err)
+		}
+	}
+	return components, nil
+}
+
+func checkComponent(component string) error {
+	if component == "" {
+		return fmt.Errorf("empty path component")
+	}
+	if component == "." || component == ".." {
+		return fmt.Errorf("relative path component %q", component)
+	}
+	if len(component) > MaxNameLength {
+		return fmt.Errorf("path component longer than %d bytes", MaxNameLength)
+	}
+	if strings.HasSuffix(component, ".") || strings.HasSuffix(component, " ") {
+		return fmt.Errorf("path component %q ends with a dot or space", component)
+	}
+	for _, suffix := range []string{PartSuffix, ProgressSuffix, progressTmpSuffix} {
+		// the partial and progress files of other transmissions must not be targeted
+		if strings.HasSuffix(component, suffix) {
+			return fmt.Errorf("path component %q ends with the reserved suffix %q", component, suffix)
+		}
+	}
+	base := strings.ToUpper(strings.SplitN(component, ".", 2)[0])
+	if reservedNames[strings.TrimRight(base, " ")] {
+		return fmt.Errorf("path component %q is a device name", component)
+	}
+	return nil
+}
+
+// ResolvePath returns the path inside root at which the file with the received name is stored.
+// Existing symbolic links and non-regular files along the path are rejected to never leave root;
+// missing directories are created if subdirs is set.
+func ResolvePath(root string, name string, subdirs bool) (string, error) {
+	components, err := SplitName(name, subdirs)
+	if err != nil {
+		return "", NewTransmissionError(packets.CodeRejected, err)
+	}
+
+	dir, err := makeDirs(root, name, components[:len(components)-1])
+	if err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(dir, components[len(components)-1])
+	info, err := os.Lstat(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", NewTransmissionError(packets.CodeIOError, err)
+	}
+	if err == nil && !info.Mode().IsRegular() {
+		return "", NewTransmissionError(packets.CodeRejected, fmt.Errorf("file name %q refers to an existing symbolic link or non-regular file", name))
+	}
+	return filePath, nil
+}
+
+// ResolveDir returns the directory inside root with the received name and creates it if missing
+func ResolveDir(root string, name string) (string, error) {
+	components, err := SplitName(name, true)
+	if err != nil {
+		return "", NewTransmissionError(packets.CodeRejected, err)
+	}
+	return makeDirs(root, name, components)
+}
+
+// makeDirs creates the missing directories of components below root; symbolic links and other files are rejected
+func makeDirs(root string, name string, components []string) (string, error) {
+	dir := root
+	for _, component := range components {
+		dir = filepath.Join(dir, component)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			err = os.Mkdir(dir, 0755)
+			if err != nil {
+				return "", NewTransmissionError(packets.CodeIOError, err)
+			}
+			continue
+		}
+		if err != nil {
+			return "", NewTransmissionError(packets.CodeIOError, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", NewTransmissionError(packets.CodeRejected, fmt.Errorf("file name %q: %q is a symbolic link", name, component))
+		}
+		if !info.IsDir() {
+			return "", NewTransmissionError(packets.CodeRejected, fmt.Errorf("file name %q: %q is not a directory", name, component))
+		}
+	}
+	return dir, nil
+}
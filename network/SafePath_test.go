@@ -0,0 +1,148 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"satae66.dev/netzeps2022/network/packets"
+	"strings"
+	"testing"
+)
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name    string
+		subdirs bool
+		want    []string // nil if the name is rejected
+	}{
+		{name: "file.txt", want: []string{"file.txt"}},
+		{name: "file.txt", subdirs: true, want: []string{"file.txt"}},
+		{name: ".hidden", want: []string{".hidden"}},
+		{name: "a/b/c.txt", subdirs: true, want: []string{"a", "b", "c.txt"}},
+		{name: "a/b/c.txt"},
+		{name: ""},
+		{name: "."},
+		{name: ".."},
+		{name: "../x", subdirs: true},
+		{name: "a/../../x", subdirs: true},
+		{name: "a/./x", subdirs: true},
+		{name: "/etc/passwd"},
+		{name: "/etc/passwd", subdirs: true},
+		{name: "a//b", subdirs: true},
+		{name: "a/", subdirs: true},
+		{name: `a\b`},
+		{name: `..\..\x`},
+		{name: "C:x"},
+		{name: "file:stream"},
+		{name: "CON"},
+		{name: "con.txt"},
+		{name: "Lpt1.tar.gz"},
+		{name: "dir/aux", subdirs: true},
+		{name: "console.txt", want: []string{"console.txt"}},
+		{name: "file."},
+		{name: "file "},
+		{name: "dir./file", subdirs: true},
+		{name: "file\x00.txt"},
+		{name: "file\n.txt"},
+		{name: "file\x7f.txt"},
+		{name: "file\u0085.txt"},
+		{name: "evil\u202etxt.exe"},
+		{name: "zero\u200bwidth"},
+		{name: "bom\ufeff"},
+		{name: "\xff\xfe"},
+		{name: strings.Repeat("x", MaxNameLength), want: []string{strings.Repeat("x", MaxNameLength)}},
+		{name: strings.Repeat("x", MaxNameLength+1)},
+		{name: ".file.txt.part"},
+		{name: "file.txt.part"},
+		{name: ".file.txt.part.progress"},
+		{name: "file.part.progress"},
+		{name: ".file.txt.part.progress.tmp"},
+		{name: "dir/.file.part", subdirs: true},
+		{name: "dir.part/file", subdirs: true},
+		{name: "über größe.txt", want: []string{"über größe.txt"}},
+	}
+	for _, test := range tests {
+		got, err := SplitName(test.name, test.subdirs)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("SplitName(%q, %v) = %q; want an error", test.name, test.subdirs, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitName(%q, %v) = %q, %v; want %q", test.name, test.subdirs, got, err, test.want)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	outside := t.TempDir()
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(outside, "secret"))
+	mustWrite(t, filepath.Join(root, "existing"))
+	mustSymlink(t, outside, filepath.Join(root, "linkdir"))
+	mustSymlink(t, filepath.Join(outside, "secret"), filepath.Join(root, "linkfile"))
+	mustSymlink(t, filepath.Join(outside, "missing"), filepath.Join(root, "dangling"))
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		subdirs  bool
+		want     string // relative to root; empty if the name is rejected
+		wantCode packets.ErrorCode
+	}{
+		{name: "new.txt", want: "new.txt"},
+		{name: "existing", want: "existing"},
+		{name: "dir/new.txt", subdirs: true, want: "dir/new.txt"},
+		{name: "a/b/new.txt", subdirs: true, want: "a/b/new.txt"},
+		{name: "a/new.txt", wantCode: packets.CodeRejected},
+		{name: "../new.txt", subdirs: true, wantCode: packets.CodeRejected},
+		{name: "a/../../new.txt", subdirs: true, wantCode: packets.CodeRejected},
+		{name: "/new.txt", wantCode: packets.CodeRejected},
+		{name: "linkdir/secret", subdirs: true, wantCode: packets.CodeRejected},
+		{name: "linkdir/new.txt", subdirs: true, wantCode: packets.CodeRejected},
+		{name: "linkfile", wantCode: packets.CodeRejected},
+		{name: "dangling", wantCode: packets.CodeRejected},
+		{name: "dir", wantCode: packets.CodeRejected},
+		{name: "existing/new.txt", subdirs: true, wantCode: packets.CodeRejected},
+		{name: "CON", wantCode: packets.CodeRejected},
+		{name: ".existing.part", wantCode: packets.CodeRejected},
+	}
+	for _, test := range tests {
+		got, err := ResolvePath(root, test.name, test.subdirs)
+		if test.want == "" {
+			if err == nil || ErrorCodeOf(err) != test.wantCode {
+				t.Errorf("ResolvePath(%q, %v) = %q, %v; want error code %v", test.name, test.subdirs, got, err, test.wantCode)
+			}
+			continue
+		}
+		if err != nil || got != filepath.Join(root, test.want) {
+			t.Errorf("ResolvePath(%q, %v) = %q, %v; want %q", test.name, test.subdirs, got, err, filepath.Join(root, test.want))
+		}
+	}
+
+	// nothing may have been created outside of root
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files outside of root; want only the secret", len(entries))
+	}
+}
+
+func mustWrite(t *testing.T, filePath string) {
+	t.Helper()
+	if err := os.WriteFile(filePath, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustSymlink(t *testing.T, target string, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+}
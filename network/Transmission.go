@@ -3,6 +3,7 @@ package network
 import (
 	"github.com/twmb/murmur3"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -12,8 +13,56 @@ type Transmission struct {
 
 	TransmittedSize uint64 // size of the already transmitted data
 	TotalSize       uint64 // total size of the file that is to be transmitted
+	SizeUnknown     bool   // the total size of a stream is only known once it was transmitted completely
 
-	Uid       uint8           // unique id of the transmission
+	Uid       uint32          // unique id of the transmission
+	Version   uint8           // header version negotiated in the info handshake
 	StartTime time.Time       // point of time at which the first data-packet was transmitted
 	Hash      murmur3.Hash128 // Hash-object used to calculate the Hash of the file
+
+	Compression CodecID // codec negotiated in the info handshake
+	Codec       Codec   // (de-)compresses the data-packets; nil without compression
+
+	Salt         []byte       // salt of the sender; the info-packet is sealed with a key derived from it
+	ReceiverSalt []byte       // salt of the receiver; sent in its replies to the info-packet, nil without acks
+	Keys         *SessionKeys // seal the packets after the info-packet; nil without a pre-shared key or until known
+
+	Rtt RttEstimator // estimation of the round-trip-time (only maintained by the sender)
+
+	mutex sync.Mutex // guards the fields read by Status against concurrent modification
+}
+
+// Status is a consistent copy of the state of a transmission, e.g. for displaying it
+type Status struct {
+	Uid             uint32
+	TransmittedSize uint64
+	TotalSize       uint64
+	SizeUnknown     bool
+	StartTime       time.Time
+	Rtt             time.Duration // smoothed round-trip-time or 0 if unknown
+	Rto             time.Duration // current retransmission-timeout
+}
+
+// Lock must be held while modifying the fields read by Status if the transmission is shared with other goroutines
+func (t *Transmission) Lock() {
+	t.mutex.Lock()
+}
+
+func (t *Transmission) Unlock() {
+	t.mutex.Unlock()
+}
+
+func (t *Transmission) Status() Status {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return Status{
+		Uid:             t.Uid,
+		TransmittedSize: t.TransmittedSize,
+		TotalSize:       t.TotalSize,
+		SizeUnknown:     t.SizeUnknown,
+		StartTime:       t.StartTime,
+		Rtt:             t.Rtt.RTT(),
+		Rto:             t.Rtt.RTO(),
+	}
 }
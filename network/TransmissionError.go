@@ -0,0 +1,46 @@
+package network
+
+import (
+	"errors"
+	"satae66.dev/netzeps2022/network/packets"
+)
+
+// TransmissionError is an error that aborted a transmission together with the ErrorCode exchanged with the peer
+type TransmissionError struct {
+	Code packets.ErrorCode
+	Err  error
+}
+
+func NewTransmissionError(code packets.ErrorCode, err error) *TransmissionError {
+	return &TransmissionError{Code: code, Err: err}
+}
+
+func (e *TransmissionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransmissionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCodeOf returns the ErrorCode of err or CodeUnknown if err is no TransmissionError
+func ErrorCodeOf(err error) packets.ErrorCode {
+	var transmissionError *TransmissionError
+	if errors.As(err, &transmissionError) {
+		return transmissionError.Code
+	}
+	return packets.CodeUnknown
+}
+
+// ReasonOf returns the reason of err that is sent to the peer; the text of i/o and unknown errors stays local, as it
+// contains the paths of the receiver
+func ReasonOf(err error) string {
+	switch ErrorCodeOf(err) {
+	case packets.CodeIOError:
+		return "the file could not be stored"
+	case packets.CodeUnknown:
+		return "internal error"
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,23 @@
+package network
+
+import (
+	"errors"
+	"os"
+	"satae66.dev/netzeps2022/network/packets"
+	"strings"
+	"testing"
+)
+
+func TestReasonOfHidesLocalPaths(t *testing.T) {
+	pathErr := &os.PathError{Op: "open", Path: "/srv/recv/alice/.x.part", Err: errors.New("no space left on device")}
+	for _, err := range []error{NewTransmissionError(packets.CodeIOError, pathErr), pathErr} {
+		if reason := ReasonOf(err); strings.Contains(reason, "/srv") {
+			t.Errorf("ReasonOf(%v) = %q; want no local path", err, reason)
+		}
+	}
+
+	rejected := NewTransmissionError(packets.CodeRejected, errors.New(`file name "a/b" contains directories`))
+	if reason := ReasonOf(rejected); reason != rejected.Error() {
+		t.Errorf("ReasonOf(%v) = %q; want the error text", rejected, reason)
+	}
+}
@@ -2,13 +2,80 @@ package network
 
 import (
 	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"satae66.dev/netzeps2022/network/packets"
 	"time"
 )
 
 type TransmissionIN struct {
 	Transmission
 
-	File *bufio.Writer
+	File     *bufio.Writer
+	Handle   *os.File     // underlying file of File
+	Path     string       // path of the received file
+	PartPath string       // path of the hidden file that is renamed to Path once the file was verified
+	Peer     *net.UDPAddr // address of the sender
+
+	Manifest *bytes.Buffer // receives a manifest in memory instead of a file
+	Batch    *Batch        // directory the file belongs to, if any
+	Entry    ManifestEntry // manifest entry of the file if it belongs to a batch
+
+	Offset    uint64   // size of the partially received file the transmission was resumed at
+	SavedSize uint64   // TransmittedSize when the progress was saved the last time
+	Resuming  *HashJob // restores Hash from the first Offset bytes of the file; nil once done
+
+	ClientUid uint32 // uid chosen by the sender for the info handshake; Uid is assigned by the receiver
+
+	Sender         *AuthorizedSender           // authenticated sender; nil for anonymous senders
+	Info           packets.InfoPacket          // info-packet that is accepted once the sender authenticated
+	Challenge      [packets.ChallengeSize]byte // challenge the sender has to sign in the auth-packet
+	Authenticating bool                        // the auth-packet is outstanding; no file is opened before
+	HandshakeSeqNr uint32                      // sequence-number of the packet that completes the handshake
+
+	WriteLimited bool   // writes are limited to WriteLeft
+	WriteLeft    uint64 // number of bytes the transmission may still write within the limits of the receiver
+	WriteLimit   Limit  // limit that determines WriteLeft
 
 	LastUpdated time.Time
+	Finished    bool      // the file was received completely and verified
+	Existing    *FileHash // hash of the existing file that is only compared with the sent one (SkipIdentical)
+
+	pending map[uint32][]byte // out-of-order data-packets by sequence-number (selective repeat only)
+}
+
+// Buffer stores the payload of an out-of-order data-packet until all preceding packets were received.
+// Packets outside the receive window of MaxWindowSize packets are rejected.
+func (t *TransmissionIN) Buffer(seqNr uint32, data []byte) bool {
+	if seqNr <= t.SeqNr || seqNr-t.SeqNr >= MaxWindowSize {
+		return false
+	}
+	if t.pending == nil {
+		t.pending = make(map[uint32][]byte)
+	}
+	t.pending[seqNr] = data
+	return true
+}
+
+// NextBuffered removes and returns the buffered payload with the next expected sequence-number
+func (t *TransmissionIN) NextBuffered() ([]byte, bool) {
+	data, ok := t.pending[t.SeqNr]
+	if ok {
+		delete(t.pending, t.SeqNr)
+	}
+	return data, ok
+}
+
+// SessionKey identifies a transmission on the receiver by the address of the sender and the uid of the stream
+type SessionKey struct {
+	Peer string
+	Uid  uint32
+}
+
+func NewSessionKey(peer *net.UDPAddr, uid uint32) SessionKey {
+	return SessionKey{
+		Peer: peer.String(),
+		Uid:  uid,
+	}
 }
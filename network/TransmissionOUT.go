@@ -0,0 +1,16 @@
+package network
+
+import (
+	"bufio"
+	"satae66.dev/netzeps2022/network/packets"
+	"time"
+)
+
+type TransmissionOUT struct {
+	Transmission
+
+	File *bufio.Reader
+
+	LastAcked time.Time                // point of time at which the last ack was received
+	Cookie    [packets.CookieSize]byte // cookie of the receiver the info-packet is sent with
+}
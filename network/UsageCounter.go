@@ -0,0 +1,57 @@
+package network
+
+import "time"
+
+// usageRefresh is the minimal time between two walks of a directory; the space of files deleted in between stays
+// counted until the next walk
+const usageRefresh = 30 * time.Second
+
+// UsageCounter tracks the total size of the files below a directory without walking it for every transmission.
+// The directory is walked in the background and the bytes written since the walk started are added to its result,
+// so the usage may be too high until the next walk but it is never too low. It is not safe for concurrent use
+type UsageCounter struct {
+	dir         string
+	known       bool      // whether a walk finished
+	usage       uint64    // size found by the last finished walk plus the bytes written since it started
+	walk        *HashJob  // running walk; nil if there is none
+	walkStart   time.Time // point of time at which the last walk started
+	walkWritten uint64    // bytes written since the running walk started
+}
+
+func NewUsageCounter(dir string) *UsageCounter {
+	return &UsageCounter{dir: dir}
+}
+
+// Usage returns the size of the files below the directory; ok is false until the first walk finished. A new walk is
+// started in the background if the last one is outdated
+func (c *UsageCounter) Usage(now time.Time) (usage uint64, ok bool, err error) {
+	if c.walk != nil && c.walk.Done() {
+		size, walkErr := c.walk.Result()
+		c.walk = nil
+		if walkErr == nil {
+			c.known = true
+			c.usage = uint64(size) + c.walkWritten
+		} else if !c.known {
+			c.walkStart = time.Time{} // the next call walks the directory again
+			return 0, false, walkErr
+		}
+	}
+	if c.walk == nil && now.Sub(c.walkStart) >= usageRefresh {
+		c.walkStart = now
+		c.walkWritten = 0
+		c.walk = startJob(func() (int64, error) {
+			size, err := DirUsage(c.dir)
+			return int64(size), err
+		})
+	}
+	return c.usage, c.known, nil
+}
+
+// Add counts n bytes written to a file below the directory
+func (c *UsageCounter) Add(n uint64) {
+	c.usage += n
+	if c.walk != nil {
+		// the walk may or may not see these bytes, so they are counted in addition to its result
+		c.walkWritten += n
+	}
+}
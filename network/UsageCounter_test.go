@@ -0,0 +1,83 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForUsage polls c until its usage is known
+func waitForUsage(t *testing.T, c *UsageCounter, now time.Time) uint64 {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		usage, ok, err := c.Usage(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			return usage
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("usage of the directory is not known")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUsageCounter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(dir, "a"))
+	mustWrite(t, filepath.Join(dir, "sub", "b"))
+
+	now := time.Now()
+	c := NewUsageCounter(dir)
+	if usage := waitForUsage(t, c, now); usage != 8 {
+		t.Errorf("usage %d; want 8", usage)
+	}
+
+	// written bytes are counted without walking the directory again
+	mustWrite(t, filepath.Join(dir, "c"))
+	c.Add(4)
+	if usage, _, _ := c.Usage(now.Add(usageRefresh / 2)); usage != 12 {
+		t.Errorf("usage %d after writing; want 12", usage)
+	}
+	if c.walk != nil {
+		t.Error("directory walked again before the refresh interval")
+	}
+
+	// a refreshing walk notices deleted files
+	if err := os.Remove(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, _ = c.Usage(now.Add(usageRefresh))
+	if c.walk == nil {
+		t.Fatal("directory not walked again after the refresh interval")
+	}
+	waitFor(t, c.walk)
+	if usage, _, _ := c.Usage(now.Add(usageRefresh)); usage != 8 {
+		t.Errorf("usage %d after the refresh; want 8", usage)
+	}
+}
+
+func TestUsageCounterMissingDir(t *testing.T) {
+	c := NewUsageCounter(filepath.Join(t.TempDir(), "missing"))
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, ok, err := c.Usage(time.Now())
+		if ok {
+			t.Fatal("usage of a missing directory is known")
+		}
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("walk of a missing directory did not fail")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,24 +1,70 @@
 package packets
 
-import "bytes"
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
 
-// AckPacketSize represents the minimum payload size of a AckPacket
-const AckPacketSize = 0
+// AckPacketSize represents the minimum payload size of a AckPacket; changes to the layout require new header
+// versions (see MinHeaderVersion)
+const AckPacketSize = 4 + 8 + 1 + ChallengeSize
+
+// ChallengeSize represents the size of the challenge the sender signs to authenticate
+const ChallengeSize = 16
+
+func init() {
+	Register(Ack, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseAckPacket(r)
+		return &p, err
+	})
+}
 
 type AckPacket struct {
 	Header
+
+	SessionID   uint32              // id assigned by the receiver; the sender uses it as StreamUID after the info handshake
+	Offset      uint64              // number of bytes of the file the receiver already has; read from the ack that completes the handshake
+	Compression uint8               // codec accepted by the receiver; read from the ack of the info-packet
+	Challenge   [ChallengeSize]byte // signed by an authenticating sender in the auth-packet; read from the ack of the info-packet
 }
 
-func NewAckPacket() AckPacket {
-	return AckPacket{}
+func NewAckPacket(sessionID uint32, offset uint64, compression uint8, challenge [ChallengeSize]byte) AckPacket {
+	return AckPacket{
+		SessionID:   sessionID,
+		Offset:      offset,
+		Compression: compression,
+		Challenge:   challenge,
+	}
 }
 
-func ParseAckPacket(_ *bytes.Reader) (AckPacket, error) {
-	return AckPacket{}, nil
+func ParseAckPacket(r *bytes.Reader) (AckPacket, error) {
+	if r.Len() < AckPacketSize {
+		return AckPacket{}, errors.New("not enough data")
+	}
+
+	buf := make([]byte, AckPacketSize)
+	_, err := r.Read(buf)
+	if err != nil {
+		return AckPacket{}, err
+	}
+
+	p := AckPacket{
+		SessionID:   binary.LittleEndian.Uint32(buf[:4]),
+		Offset:      binary.LittleEndian.Uint64(buf[4:12]),
+		Compression: buf[12],
+	}
+	copy(p.Challenge[:], buf[13:AckPacketSize])
+	return p, nil
 }
 
 func (p AckPacket) ToBytes() []byte {
-	return []byte{}
+	raw := make([]byte, AckPacketSize)
+	binary.LittleEndian.PutUint32(raw[:4], p.SessionID)
+	binary.LittleEndian.PutUint64(raw[4:12], p.Offset)
+	raw[12] = p.Compression
+	copy(raw[13:AckPacketSize], p.Challenge[:])
+	return raw
 }
 
 func (p AckPacket) Type() PacketType {
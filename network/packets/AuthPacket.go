@@ -0,0 +1,52 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+)
+
+// AuthPacketSize represents the payload size of a AuthPacket
+const AuthPacketSize = ed25519.SignatureSize
+
+func init() {
+	Register(Auth, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseAuthPacket(r)
+		return &p, err
+	})
+}
+
+// AuthPacket follows the info-packet of an authenticating sender; it proves the possession of the private key of
+// InfoPacket.PublicKey by signing the challenge of the receiver
+type AuthPacket struct {
+	Header
+
+	Signature [ed25519.SignatureSize]byte
+}
+
+func NewAuthPacket(signature []byte) AuthPacket {
+	p := AuthPacket{}
+	copy(p.Signature[:], signature)
+	return p
+}
+
+func ParseAuthPacket(r *bytes.Reader) (AuthPacket, error) {
+	if r.Len() < AuthPacketSize {
+		return AuthPacket{}, errors.New("not enough data")
+	}
+
+	p := AuthPacket{}
+	_, err := r.Read(p.Signature[:])
+	if err != nil {
+		return AuthPacket{}, err
+	}
+	return p, nil
+}
+
+func (p AuthPacket) ToBytes() []byte {
+	return append([]byte(nil), p.Signature[:]...)
+}
+
+func (p AuthPacket) Type() PacketType {
+	return Auth
+}
@@ -0,0 +1,45 @@
+package packets
+
+import (
+	"bytes"
+)
+
+// CompressedDataPacketSize represents the minimum payload size of a CompressedDataPacket
+const CompressedDataPacketSize = 1
+
+func init() {
+	Register(CompressedData, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseCompressedDataPacket(r)
+		return &p, err
+	})
+}
+
+// CompressedDataPacket carries a chunk of the file compressed with the codec negotiated in the info handshake;
+// every packet is compressed independently to keep lost packets from breaking the following ones
+type CompressedDataPacket struct {
+	Header
+
+	Data []byte
+}
+
+func NewCompressedDataPacket(data []byte) CompressedDataPacket {
+	return CompressedDataPacket{
+		Data: data,
+	}
+}
+
+func ParseCompressedDataPacket(r *bytes.Reader) (CompressedDataPacket, error) {
+	buf := make([]byte, r.Len())
+	_, err := r.Read(buf)
+	return CompressedDataPacket{Data: buf}, err
+}
+
+func (p CompressedDataPacket) ToBytes() []byte {
+	var raw []byte
+	raw = append(raw, p.Data...)
+	return raw
+}
+
+func (p CompressedDataPacket) Type() PacketType {
+	return CompressedData
+}
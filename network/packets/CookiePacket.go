@@ -0,0 +1,54 @@
+package packets
+
+import (
+	"bytes"
+	"errors"
+)
+
+// CookieSize represents the size of a cookie
+const CookieSize = 16
+
+// CookiePacketSize represents the payload size of a CookiePacket
+const CookiePacketSize = CookieSize
+
+func init() {
+	Register(Cookie, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseCookiePacket(r)
+		return &p, err
+	})
+}
+
+// CookiePacket answers an info-packet without a valid cookie; the sender has to send the info-packet again with the
+// cookie to prove that it receives packets at its address. It is never sealed, as the receiver keeps no state for it
+type CookiePacket struct {
+	Header
+
+	Cookie [CookieSize]byte
+}
+
+func NewCookiePacket(cookie [CookieSize]byte) CookiePacket {
+	return CookiePacket{
+		Cookie: cookie,
+	}
+}
+
+func ParseCookiePacket(r *bytes.Reader) (CookiePacket, error) {
+	if r.Len() < CookiePacketSize {
+		return CookiePacket{}, errors.New("not enough data")
+	}
+
+	p := CookiePacket{}
+	_, err := r.Read(p.Cookie[:])
+	if err != nil {
+		return CookiePacket{}, err
+	}
+	return p, nil
+}
+
+func (p CookiePacket) ToBytes() []byte {
+	return append([]byte(nil), p.Cookie[:]...)
+}
+
+func (p CookiePacket) Type() PacketType {
+	return Cookie
+}
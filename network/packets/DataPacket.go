@@ -7,6 +7,13 @@ import (
 // DataPacketSize represents the minimum payload size of a DataPacket
 const DataPacketSize = 1
 
+func init() {
+	Register(Data, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseDataPacket(r)
+		return &p, err
+	})
+}
+
 type DataPacket struct {
 	Header
 
@@ -1,32 +1,96 @@
 package packets
 
-import "bytes"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
 
 // ErrorPacketSize represents the minimum payload size of a ErrorPacket
 const ErrorPacketSize = 1
 
+// ErrorCode tells the peer why a transmission was aborted
+type ErrorCode byte
+
+const (
+	CodeUnknown            ErrorCode = 0x00
+	CodeChecksumMismatch   ErrorCode = 0x01 // the hash of the received file does not match the checksum of the FinalizePacket
+	CodeFileExists         ErrorCode = 0x02 // a file already exists at the requested path
+	CodeIOError            ErrorCode = 0x03 // the file could not be created, written or read
+	CodeProtocolViolation  ErrorCode = 0x04 // malformed or unexpected packet
+	CodeTimeout            ErrorCode = 0x05 // the peer did not respond in time
+	CodeRejected           ErrorCode = 0x06 // the transmission was refused by the peer
+	CodeUnsupportedVersion ErrorCode = 0x07 // no common header version could be negotiated
+	CodeUnauthorized       ErrorCode = 0x08 // the sender did not authenticate with an authorized key
+	CodeQuotaExceeded      ErrorCode = 0x09 // the file exceeds a size limit of the receiver or the space left in a quota
+	CodeTooManySessions    ErrorCode = 0x0A // the sender has too many concurrent transmissions
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	CodeUnknown:            "unknown error",
+	CodeChecksumMismatch:   "checksum mismatch",
+	CodeFileExists:         "file exists",
+	CodeIOError:            "i/o error",
+	CodeProtocolViolation:  "protocol violation",
+	CodeTimeout:            "timeout",
+	CodeRejected:           "rejected",
+	CodeUnsupportedVersion: "unsupported version",
+	CodeUnauthorized:       "unauthorized",
+	CodeQuotaExceeded:      "quota exceeded",
+	CodeTooManySessions:    "too many sessions",
+}
+
+func (c ErrorCode) String() string {
+	name, ok := errorCodeNames[c]
+	if !ok {
+		return fmt.Sprintf("ErrorCode(%d)", uint8(c))
+	}
+	return name
+}
+
+func init() {
+	Register(Error, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseErrorPacket(r)
+		return &p, err
+	})
+}
+
 type ErrorPacket struct {
 	Header
 
+	code   ErrorCode
 	reason string
 }
 
-func NewErrorPacket(reason string) ErrorPacket {
-	return ErrorPacket{reason: reason}
+func NewErrorPacket(code ErrorCode, reason string) ErrorPacket {
+	return ErrorPacket{code: code, reason: reason}
 }
 
 func ParseErrorPacket(r *bytes.Reader) (ErrorPacket, error) {
+	if r.Len() < ErrorPacketSize {
+		return ErrorPacket{}, errors.New("not enough data")
+	}
 	buf := make([]byte, r.Len())
 	_, err := r.Read(buf)
 	if err != nil {
 		return ErrorPacket{}, err
 	}
 
-	return ErrorPacket{reason: string(buf)}, nil
+	return ErrorPacket{code: ErrorCode(buf[0]), reason: string(buf[1:])}, nil
+}
+
+// Code returns the machine-readable reason of the error
+func (p ErrorPacket) Code() ErrorCode {
+	return p.code
+}
+
+// Reason returns the human-readable reason of the error
+func (p ErrorPacket) Reason() string {
+	return p.reason
 }
 
 func (p ErrorPacket) ToBytes() []byte {
-	return []byte(p.reason)
+	return append([]byte{byte(p.code)}, []byte(p.reason)...)
 }
 
 func (p ErrorPacket) Type() PacketType {
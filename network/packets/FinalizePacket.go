@@ -9,6 +9,13 @@ import (
 // FinalizePacketSize represents the payload size of a FinalizePacket
 const FinalizePacketSize = 16
 
+func init() {
+	Register(Finalize, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseFinalizePacket(r)
+		return &p, err
+	})
+}
+
 type FinalizePacket struct {
 	Header
 
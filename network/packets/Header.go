@@ -4,25 +4,105 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 )
 
-const HeaderSize = 4 + 1 + 1
+// Versions of the header layout understood by this implementation; the version is the first byte of every header.
+// The version also identifies the layout of the info- and ack-packets, so every change to their payload requires new
+// version numbers; a peer with another layout is rejected before its handshake is misinterpreted.
+// Version 1 was the unversioned 6-byte layout with an 8 bit StreamUID; versions below MinHeaderVersion are no longer
+// supported. ChecksumHeaderVersion appends a CRC32C checksum over the whole packet to the layout of MinHeaderVersion.
+// SealedHeaderVersion uses the layout of MinHeaderVersion for packets sealed with a pre-shared key; it is not negotiated.
+const (
+	MinHeaderVersion      = 16 // used for info-packets and protocols without negotiation, as every peer understands it
+	ChecksumHeaderVersion = 17
+	MaxHeaderVersion      = 17
+	SealedHeaderVersion   = 18 // used for all packets if both peers share a key (see Seal)
+)
+
+// HeaderSize represents the size of a header of MinHeaderVersion
+const HeaderSize = 1 + 1 + 4 + 4
+
+// ChecksumHeaderSize represents the size of a header of ChecksumHeaderVersion
+const ChecksumHeaderSize = HeaderSize + 4
+
+// MaxHeaderSize represents the size of the largest supported header
+const MaxHeaderSize = ChecksumHeaderSize
+
+var ErrChecksumMismatch = errors.New("packet checksum mismatch")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// HeaderSizeOf returns the size of a header of the given version
+func HeaderSizeOf(version uint8) int {
+	if version == ChecksumHeaderVersion {
+		return ChecksumHeaderSize
+	}
+	return HeaderSize
+}
+
+// UnsupportedVersionError is returned when a packet uses a header version this implementation does not understand
+type UnsupportedVersionError struct {
+	Version uint8
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported header version %d; supported versions are %d to %d", e.Version, MinHeaderVersion, MaxHeaderVersion)
+}
+
+func IsSupportedVersion(version uint8) bool {
+	return version >= MinHeaderVersion && version <= MaxHeaderVersion
+}
+
+// NegotiateVersion returns the highest header version supported by both this implementation and a peer supporting
+// every version from MinHeaderVersion up to peerMaxVersion
+func NegotiateVersion(peerMaxVersion uint8) (uint8, error) {
+	if peerMaxVersion < MinHeaderVersion {
+		return 0, &UnsupportedVersionError{Version: peerMaxVersion}
+	}
+	if peerMaxVersion > MaxHeaderVersion {
+		return MaxHeaderVersion, nil
+	}
+	return peerMaxVersion, nil
+}
 
 type Header struct {
-	SequenceNr uint32
-	StreamUID  uint8
+	Version    uint8
 	PacketType PacketType
+	SequenceNr uint32
+	StreamUID  uint32
 }
 
-func NewHeader(sequenceNr uint32, streamUID uint8, packetType PacketType) Header {
+// NewHeader creates a header of MinHeaderVersion; Version has to be set to the negotiated version where applicable
+func NewHeader(sequenceNr uint32, streamUID uint32, packetType PacketType) Header {
 	return Header{
+		Version:    MinHeaderVersion,
+		PacketType: packetType,
 		SequenceNr: sequenceNr,
 		StreamUID:  streamUID,
-		PacketType: packetType,
 	}
 }
 
+// ParseHeader parses the header according to its version; unknown versions are rejected with an UnsupportedVersionError
 func ParseHeader(r *bytes.Reader) (Header, error) {
+	if r.Len() < 1 {
+		return Header{}, errors.New("not enough data")
+	}
+	version, _ := r.ReadByte()
+	_ = r.UnreadByte()
+
+	switch version {
+	case MinHeaderVersion, SealedHeaderVersion:
+		return parsePlainHeader(r)
+	case ChecksumHeaderVersion:
+		return parseChecksumHeader(r)
+	default:
+		return Header{}, &UnsupportedVersionError{Version: version}
+	}
+}
+
+func parsePlainHeader(r *bytes.Reader) (Header, error) {
 	data := make([]byte, HeaderSize)
 	n, err := r.Read(data)
 	if err != nil {
@@ -34,24 +114,74 @@ func ParseHeader(r *bytes.Reader) (Header, error) {
 	}
 
 	return Header{
-		SequenceNr: binary.LittleEndian.Uint32(data[:4]),
-		StreamUID:  data[4],
-		PacketType: PacketType(data[5]),
+		Version:    data[0],
+		PacketType: PacketType(data[1]),
+		SequenceNr: binary.LittleEndian.Uint32(data[2:6]),
+		StreamUID:  binary.LittleEndian.Uint32(data[6:10]),
 	}, nil
 }
 
+// parseChecksumHeader parses a header of MinHeaderVersion and validates the checksum over the whole packet behind it
+func parseChecksumHeader(r *bytes.Reader) (Header, error) {
+	packet := make([]byte, r.Size())
+	_, err := r.ReadAt(packet, 0)
+	if err != nil {
+		return Header{}, err
+	}
+
+	header, err := parsePlainHeader(r)
+	if err != nil {
+		return Header{}, err
+	}
+
+	checksum := make([]byte, 4)
+	n, err := r.Read(checksum)
+	if err != nil || n < 4 {
+		return Header{}, errors.New("not enough data")
+	}
+
+	// the checksum is calculated with the checksum-field set to zero
+	copy(packet[HeaderSize:ChecksumHeaderSize], []byte{0, 0, 0, 0})
+	if crc32.Checksum(packet, crc32cTable) != binary.LittleEndian.Uint32(checksum) {
+		return Header{}, ErrChecksumMismatch
+	}
+
+	return header, nil
+}
+
+func (h Header) GetHeader() Header {
+	return h
+}
+
 func (h *Header) SetHeader(data Header) {
+	h.Version = data.Version
 	h.StreamUID = data.StreamUID
 	h.SequenceNr = data.SequenceNr
 	h.PacketType = data.PacketType
 }
 
+// Size returns the size of the header on the wire
+func (h *Header) Size() int {
+	return HeaderSizeOf(h.Version)
+}
+
+// ToBytes serializes the header; the checksum of ChecksumHeaderVersion is only filled in by Pack
 func (h *Header) ToBytes() []byte {
-	raw := make([]byte, HeaderSize)
+	raw := make([]byte, h.Size())
+
+	raw[0] = h.Version
+	raw[1] = uint8(h.PacketType)
+	binary.LittleEndian.PutUint32(raw[2:6], h.SequenceNr)
+	binary.LittleEndian.PutUint32(raw[6:10], h.StreamUID)
 
-	binary.LittleEndian.PutUint32(raw[:4], h.SequenceNr)
-	raw[4] = h.StreamUID
-	raw[5] = uint8(h.PacketType)
+	return raw
+}
 
+// Pack serializes the header followed by payload and fills in the checksum where the version requires it
+func (h *Header) Pack(payload []byte) []byte {
+	raw := append(h.ToBytes(), payload...)
+	if h.Version == ChecksumHeaderVersion {
+		binary.LittleEndian.PutUint32(raw[HeaderSize:ChecksumHeaderSize], crc32.Checksum(raw, crc32cTable))
+	}
 	return raw
 }
@@ -0,0 +1,58 @@
+package packets
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		peerMaxVersion uint8
+		want           uint8
+		wantErr        bool
+	}{
+		{peerMaxVersion: 1, wantErr: true},
+		{peerMaxVersion: MinHeaderVersion - 1, wantErr: true},
+		{peerMaxVersion: MinHeaderVersion, want: MinHeaderVersion},
+		{peerMaxVersion: ChecksumHeaderVersion, want: ChecksumHeaderVersion},
+		{peerMaxVersion: MaxHeaderVersion + 1, want: MaxHeaderVersion},
+	}
+	for _, test := range tests {
+		got, err := NegotiateVersion(test.peerMaxVersion)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("NegotiateVersion(%d) = %d, %v; want %d, error %v", test.peerMaxVersion, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestParseHeaderRejectsUnsupportedVersions(t *testing.T) {
+	for _, version := range []uint8{1, MinHeaderVersion - 1, SealedHeaderVersion + 1} {
+		header := NewHeader(0, 1, Info)
+		header.Version = version
+
+		_, err := ParseHeader(bytes.NewReader(header.Pack(NewInfoPacket(1, version, false, "file").ToBytes())))
+		var versionErr *UnsupportedVersionError
+		if !errors.As(err, &versionErr) || versionErr.Version != version {
+			t.Errorf("ParseHeader of version %d: got error %v, want UnsupportedVersionError", version, err)
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, version := range []uint8{MinHeaderVersion, ChecksumHeaderVersion} {
+		info := NewInfoPacket(42, MaxHeaderVersion, true, "dir/file")
+		header := NewHeader(0, 7, Info)
+		header.Version = version
+		info.SetHeader(header)
+
+		p, err := Decode(Encode(&info))
+		if err != nil {
+			t.Fatalf("Decode of version %d: %v", version, err)
+		}
+		got, ok := p.(*InfoPacket)
+		if !ok || got.Filesize != 42 || !got.Resume || got.Filename != "dir/file" || got.GetHeader() != header {
+			t.Errorf("Decode of version %d = %+v; want %+v", version, p, info)
+		}
+	}
+}
@@ -2,24 +2,50 @@ package packets
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"encoding/binary"
 	"errors"
 )
 
-// InfoPacketSize represents the minimum payload size of a InfoPacket
-const InfoPacketSize = 8
+// InfoPacketSize represents the minimum payload size of a InfoPacket; changes to the layout require new header
+// versions (see MinHeaderVersion)
+const InfoPacketSize = 8 + 1 + 1 + 4 + 1 + ed25519.PublicKeySize + CookieSize
+
+// flags of the InfoPacket
+const (
+	infoFlagResume   = 1 << iota // the sender asks to continue a previously interrupted transmission
+	infoFlagManifest             // the transmitted data is the manifest of a directory
+	infoFlagStream               // the size of the transmitted data is unknown
+)
+
+func init() {
+	Register(Info, func(r *bytes.Reader) (Packet, error) {
+		p, err := ParseInfoPacket(r)
+		return &p, err
+	})
+}
 
 type InfoPacket struct {
 	Header
 
-	Filesize uint64
-	Filename string
+	Filesize    uint64
+	MaxVersion  uint8                       // highest header version supported by the sender
+	Resume      bool                        // the receiver shall answer with the number of bytes it already has of the file
+	Manifest    bool                        // the data is the manifest of the directory Filename instead of a file
+	Stream      bool                        // the size is unknown and Filesize is 0; the end of the data is signalled by the finalize-packet
+	Batch       uint32                      // uid of the manifest transmission if the file belongs to a directory; Filename is relative to it
+	Compression uint8                       // codec the sender wants to compress the data-packets with; 0 for none
+	PublicKey   [ed25519.PublicKeySize]byte // key the sender authenticates with in the auth-packet; zero if anonymous
+	Cookie      [CookieSize]byte            // cookie of the receiver from the cookie-packet; zero until received
+	Filename    string
 }
 
-func NewInfoPacket(filesize uint64, filename string) InfoPacket {
+func NewInfoPacket(filesize uint64, maxVersion uint8, resume bool, filename string) InfoPacket {
 	return InfoPacket{
-		Filesize: filesize,
-		Filename: filename,
+		Filesize:   filesize,
+		MaxVersion: maxVersion,
+		Resume:     resume,
+		Filename:   filename,
 	}
 }
 
@@ -33,15 +59,43 @@ func ParseInfoPacket(r *bytes.Reader) (InfoPacket, error) {
 		return InfoPacket{}, err
 	}
 
-	return InfoPacket{
-		Filesize: binary.LittleEndian.Uint64(buf[:8]),
-		Filename: string(buf[8:]),
-	}, nil
+	p := InfoPacket{
+		Filesize:    binary.LittleEndian.Uint64(buf[:8]),
+		MaxVersion:  buf[8],
+		Resume:      buf[9]&infoFlagResume != 0,
+		Manifest:    buf[9]&infoFlagManifest != 0,
+		Stream:      buf[9]&infoFlagStream != 0,
+		Batch:       binary.LittleEndian.Uint32(buf[10:14]),
+		Compression: buf[14],
+		Filename:    string(buf[InfoPacketSize:]),
+	}
+	copy(p.PublicKey[:], buf[15:15+ed25519.PublicKeySize])
+	copy(p.Cookie[:], buf[15+ed25519.PublicKeySize:InfoPacketSize])
+	return p, nil
+}
+
+// Anonymous reports whether the sender does not authenticate
+func (p InfoPacket) Anonymous() bool {
+	return p.PublicKey == [ed25519.PublicKeySize]byte{}
 }
 
 func (p InfoPacket) ToBytes() []byte {
-	raw := make([]byte, 8)
-	binary.LittleEndian.PutUint64(raw[:], p.Filesize)
+	raw := make([]byte, InfoPacketSize)
+	binary.LittleEndian.PutUint64(raw[:8], p.Filesize)
+	raw[8] = p.MaxVersion
+	if p.Resume {
+		raw[9] |= infoFlagResume
+	}
+	if p.Manifest {
+		raw[9] |= infoFlagManifest
+	}
+	if p.Stream {
+		raw[9] |= infoFlagStream
+	}
+	binary.LittleEndian.PutUint32(raw[10:14], p.Batch)
+	raw[14] = p.Compression
+	copy(raw[15:15+ed25519.PublicKeySize], p.PublicKey[:])
+	copy(raw[15+ed25519.PublicKeySize:InfoPacketSize], p.Cookie[:])
 	return append(raw, []byte(p.Filename)...)
 }
 
@@ -1,16 +1,78 @@
 package packets
 
+import (
+	"bytes"
+	"fmt"
+)
+
 type PacketType byte
 
 const (
-	Info     PacketType = 0x00
-	Data                = 0x01
-	Error               = 0xFD
-	Ack                 = 0xFE
-	Finalize            = 0xFF
+	Info           PacketType = 0x00
+	Data           PacketType = 0x01
+	CompressedData PacketType = 0x02
+	Auth           PacketType = 0x03
+	Cookie         PacketType = 0x04
+	Error          PacketType = 0xFD
+	Ack            PacketType = 0xFE
+	Finalize       PacketType = 0xFF
 )
 
+// Packet is implemented by pointers to all packet types; the header is embedded in every packet
 type Packet interface {
-	ToBytes() []byte
+	ToBytes() []byte // serializes the payload without the header
 	Type() PacketType
+	GetHeader() Header
+	SetHeader(data Header)
+}
+
+// Decoder parses the payload of a packet type behind the header
+type Decoder func(r *bytes.Reader) (Packet, error)
+
+var decoders = make(map[PacketType]Decoder)
+
+// Register makes a packet type known to Decode; packet types register themselves in an init function
+func Register(packetType PacketType, decoder Decoder) {
+	if decoder == nil {
+		panic("packets: Register decoder is nil")
+	}
+	if _, ok := decoders[packetType]; ok {
+		panic(fmt.Sprintf("packets: Register called twice for packet type %#x", byte(packetType)))
+	}
+	decoders[packetType] = decoder
+}
+
+// Decode parses a whole packet including its header; sealed packets have to be parsed with Open
+func Decode(raw []byte) (Packet, error) {
+	r := bytes.NewReader(raw)
+	header, err := ParseHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if header.Version == SealedHeaderVersion {
+		return nil, ErrSealed
+	}
+	return decodePayload(header, r)
+}
+
+// decodePayload parses the payload behind header with the decoder of its packet type
+func decodePayload(header Header, r *bytes.Reader) (Packet, error) {
+	decoder, ok := decoders[header.PacketType]
+	if !ok {
+		return nil, fmt.Errorf("unknown packet type %#x", byte(header.PacketType))
+	}
+
+	p, err := decoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("malformed packet with header %v: %w", header, err)
+	}
+	p.SetHeader(header)
+	return p, nil
+}
+
+// Encode serializes the header and payload of p; the packet type of the header is taken from p
+func Encode(p Packet) []byte {
+	header := p.GetHeader()
+	header.PacketType = p.Type()
+	return header.Pack(p.ToBytes())
 }
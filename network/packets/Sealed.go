@@ -0,0 +1,90 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// SaltSize represents the size of the salt that precedes the ciphertext of sealed packets with sequence-number 0
+const SaltSize = 16
+
+// SealOverhead represents the size of the authentication tag that is appended to the ciphertext of sealed packets
+const SealOverhead = 16
+
+// ErrSealed is returned by Decode for packets that were sealed with a key
+var ErrSealed = errors.New("packet is sealed with a pre-shared key")
+
+// ErrNotSealed is returned by Open for packets that were not sealed
+var ErrNotSealed = errors.New("packet is not sealed")
+
+// ErrAuthentication is returned by Open if a packet was not sealed with the given key or was modified
+var ErrAuthentication = errors.New("packet authentication failed")
+
+// Seal encrypts the payload of p with aead, which must have SealOverhead bytes of overhead.
+// The header of SealedHeaderVersion is authenticated and the nonce is derived from the sequence-number, the stream-uid
+// and the packet type, so every key must only be used for a single transmission in a single direction.
+// Packets with sequence-number 0 carry salt in plain text behind the header to let the peer derive the key.
+func Seal(aead cipher.AEAD, p Packet, salt []byte) []byte {
+	header := p.GetHeader()
+	header.Version = SealedHeaderVersion
+	header.PacketType = p.Type()
+
+	raw := header.ToBytes()
+	if header.SequenceNr == 0 {
+		plainSalt := make([]byte, SaltSize)
+		copy(plainSalt, salt)
+		raw = append(raw, plainSalt...)
+	}
+	return aead.Seal(raw, nonceOf(header), p.ToBytes(), raw)
+}
+
+// ParseSealed parses the header and the salt of a sealed packet without authenticating them;
+// the salt is nil unless the sequence-number is 0
+func ParseSealed(raw []byte) (Header, []byte, error) {
+	header, err := ParseHeader(bytes.NewReader(raw))
+	if err != nil {
+		return Header{}, nil, err
+	}
+	if header.Version != SealedHeaderVersion {
+		return Header{}, nil, ErrNotSealed
+	}
+
+	size := HeaderSize
+	if header.SequenceNr == 0 {
+		size += SaltSize
+	}
+	if len(raw) < size+SealOverhead {
+		return Header{}, nil, errors.New("not enough data")
+	}
+	if header.SequenceNr != 0 {
+		return header, nil, nil
+	}
+	return header, raw[HeaderSize:size], nil
+}
+
+// Open authenticates and decrypts a packet sealed with the key of aead and parses it
+func Open(aead cipher.AEAD, raw []byte) (Packet, error) {
+	header, salt, err := ParseSealed(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	size := HeaderSize + len(salt)
+	payload, err := aead.Open(nil, nonceOf(header), raw[size:], raw[:size])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header %v", ErrAuthentication, header)
+	}
+	return decodePayload(header, bytes.NewReader(payload))
+}
+
+// nonceOf returns the 12 byte nonce of a sealed packet; a sender never seals two packets with the same header
+func nonceOf(header Header) []byte {
+	nonce := make([]byte, 12)
+	binary.LittleEndian.PutUint32(nonce[:4], header.SequenceNr)
+	binary.LittleEndian.PutUint32(nonce[4:8], header.StreamUID)
+	nonce[8] = byte(header.PacketType)
+	return nonce
+}
@@ -0,0 +1,102 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
+
+func newTestAEAD(t *testing.T, key byte) cipher.AEAD {
+	t.Helper()
+	block, err := aes.NewCipher(bytes.Repeat([]byte{key}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func newSealedData(seqNr uint32) *DataPacket {
+	p := NewDataPacket([]byte("sealed payload"))
+	p.SetHeader(NewHeader(seqNr, 7, Data))
+	return &p
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	aead := newTestAEAD(t, 1)
+	salt := bytes.Repeat([]byte{0xAA}, SaltSize)
+
+	for _, seqNr := range []uint32{0, 1, 1000} {
+		raw := Seal(aead, newSealedData(seqNr), salt)
+		if bytes.Contains(raw, []byte("sealed payload")) {
+			t.Errorf("packet %d: payload is not encrypted", seqNr)
+		}
+
+		header, gotSalt, err := ParseSealed(raw)
+		if err != nil {
+			t.Fatalf("packet %d: %v", seqNr, err)
+		}
+		if header.Version != SealedHeaderVersion || header.SequenceNr != seqNr || header.StreamUID != 7 {
+			t.Errorf("packet %d: header %+v", seqNr, header)
+		}
+		// only packets with sequence-number 0 carry the salt
+		if (seqNr == 0) != (gotSalt != nil) || (gotSalt != nil && !bytes.Equal(gotSalt, salt)) {
+			t.Errorf("packet %d: salt %x", seqNr, gotSalt)
+		}
+
+		p, err := Open(aead, raw)
+		if err != nil {
+			t.Fatalf("packet %d: %v", seqNr, err)
+		}
+		data, ok := p.(*DataPacket)
+		if !ok || string(data.Data) != "sealed payload" || data.GetHeader() != header {
+			t.Errorf("packet %d: opened %+v", seqNr, p)
+		}
+	}
+}
+
+func TestOpenRejectsModifiedPackets(t *testing.T) {
+	aead := newTestAEAD(t, 1)
+	raw := Seal(aead, newSealedData(0), bytes.Repeat([]byte{0xAA}, SaltSize))
+
+	tests := []struct {
+		name  string
+		index int
+	}{
+		{"packet type", 1},
+		{"sequence-number", 2},
+		{"stream-uid", 6},
+		{"salt", HeaderSize},
+		{"ciphertext", HeaderSize + SaltSize},
+		{"tag", len(raw) - 1},
+	}
+	for _, test := range tests {
+		modified := append([]byte(nil), raw...)
+		modified[test.index] ^= 0x01
+		if _, err := Open(aead, modified); err == nil {
+			t.Errorf("modified %s: opened", test.name)
+		}
+	}
+
+	if _, err := Open(aead, raw[:len(raw)-1]); err == nil {
+		t.Error("truncated packet: opened")
+	}
+	if _, err := Open(newTestAEAD(t, 2), raw); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("wrong key: got error %v; want %v", err, ErrAuthentication)
+	}
+}
+
+func TestSealedAndPlainPacketsAreNotMixedUp(t *testing.T) {
+	aead := newTestAEAD(t, 1)
+	if _, err := Decode(Seal(aead, newSealedData(1), nil)); !errors.Is(err, ErrSealed) {
+		t.Errorf("Decode of a sealed packet: got error %v; want %v", err, ErrSealed)
+	}
+	if _, err := Open(aead, Encode(newSealedData(1))); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("Open of a plain packet: got error %v; want %v", err, ErrNotSealed)
+	}
+}